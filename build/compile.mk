@@ -54,5 +54,11 @@ compile-linux: deps-only compile-only
 compile-freebsd: GOOS=freebsd
 compile-freebsd: deps-only compile-only
 
+# Regenerate the protobuf and gRPC code of the service
+proto:
+	@echo "=== $(PROJECT_NAME) === [ proto            ]: generating protobuf code..."
+	@protoc --go_out=. --go_opt=paths=source_relative \
+		--go-grpc_out=. --go-grpc_opt=paths=source_relative \
+		pkg/service/service.proto
 
-.PHONY: clean-compile compile compile-darwin compile-linux compile-only compile-freebsd
+.PHONY: clean-compile compile proto compile-darwin compile-linux compile-only compile-freebsd
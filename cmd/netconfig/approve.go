@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	prompt "github.com/c-bata/go-prompt"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// promptApprover asks the operator on the terminal whether to commit the diff
+// of each host.
+type promptApprover struct{}
+
+var approvalChoices = []prompt.Suggest{
+	{Text: "commit", Description: "commit the candidate configuration"},
+	{Text: "skip", Description: "discard the candidate configuration"},
+	{Text: "confirmed", Description: "commit confirmed, rolled back unless confirmed"},
+	{Text: "abort", Description: "discard this and all remaining hosts"},
+}
+
+// Approve implements netconfig.Approver.
+func (p *promptApprover) Approve(host netconfig.Host, diff string) (netconfig.Decision, error) {
+	fmt.Printf("\n=== %s ===\n%s\n", host.HostName, netconfig.ColorizeDiff(diff))
+
+	for {
+		answer := prompt.Input(host.HostName+" [commit/skip/confirmed/abort]> ", approvalCompleter)
+
+		switch strings.TrimSpace(answer) {
+		case "commit", "c":
+			return netconfig.DecisionCommit, nil
+		case "skip", "s":
+			return netconfig.DecisionSkip, nil
+		case "confirmed", "cc":
+			return netconfig.DecisionCommitConfirmed, nil
+		case "abort", "a":
+			return netconfig.DecisionAbort, nil
+		}
+
+		fmt.Println("please answer commit, skip, confirmed or abort")
+	}
+}
+
+func approvalCompleter(d prompt.Document) []prompt.Suggest {
+	return prompt.FilterHasPrefix(approvalChoices, d.GetWordBeforeCursor(), true)
+}
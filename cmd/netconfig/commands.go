@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// runCommand executes the command named by the first argument.  With no
+// arguments, the network is configured.
+func runCommand(nc *netconfig.NetConfig, cfg *netconfig.Config, args []string) error {
+	if len(args) == 0 {
+		return pushCommand(nc)
+	}
+
+	switch args[0] {
+	case "push":
+		return pushCommand(nc)
+	case "validate":
+		return nc.ValidateNetwork()
+	case "backup":
+		return backupCommand(nc, args[1:])
+	case "restore":
+		return restoreCommand(nc, args[1:])
+	case "history":
+		return historyCommand(nc, args[1:])
+	case "rollback":
+		return rollbackCommand(nc, args[1:])
+	case "schedule":
+		return scheduleCommand(nc, args[1:])
+	case "test":
+		return testCommand(nc, cfg.Update)
+	case "verify-topology":
+		return verifyTopologyCommand(nc, cfg.JSON)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// pushCommand configures the network, and reports the hosts which were skipped
+// as unchanged since their configuration was last applied, as unhealthy or as
+// having the uncommitted changes of another user, and the operational checks
+// and routing engine commit results of the hosts which were committed.
+func pushCommand(nc *netconfig.NetConfig) error {
+	err := nc.ConfigureNetwork()
+
+	report := nc.Report()
+	if printErr := printReport(report); printErr != nil {
+		return printErr
+	}
+
+	var skipped, drifted []string
+	for _, r := range report.Hosts {
+		if r.Skipped {
+			skipped = append(skipped, r.Host)
+		}
+		if r.Drifted {
+			drifted = append(drifted, r.Host)
+		}
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("skipped %d unchanged host(s): %s\n", len(skipped), strings.Join(skipped, ", "))
+	}
+
+	if len(drifted) > 0 {
+		fmt.Printf("%d unchanged host(s) drifted, push them with -force: %s\n", len(drifted), strings.Join(drifted, ", "))
+	}
+
+	return err
+}
+
+// printReport prints the results of the hosts of a run which changed their
+// configuration: the checks and routing engines of each commit, and the hosts
+// which were refused or rolled back.
+func printReport(report netconfig.RunReport) error {
+	var (
+		rolledBack            []string
+		checked, synchronized []netconfig.HostResult
+	)
+	for _, r := range report.Hosts {
+		if r.RolledBack {
+			rolledBack = append(rolledBack, r.Host)
+		}
+		if len(r.Checks) > 0 {
+			checked = append(checked, r)
+		}
+		if len(r.RoutingEngines) > 0 {
+			synchronized = append(synchronized, r)
+		}
+	}
+
+	if len(checked) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tCHECK\tBEFORE\tAFTER\tREGRESSIONS")
+		for _, r := range checked {
+			for _, c := range r.Checks {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", r.Host, c.Name, c.Before, c.After, strings.Join(c.Regressions, "; "))
+			}
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+
+	if len(synchronized) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tROUTING ENGINE\tMASTERSHIP\tCOMMITTED\tERROR")
+		for _, r := range synchronized {
+			for _, re := range r.RoutingEngines {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", r.Host, re.Name, re.Mastership, re.Committed, re.Err)
+			}
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+
+	for _, r := range report.Hosts {
+		if len(r.Preflight) > 0 {
+			fmt.Printf("skipped unhealthy host %s: %s\n", r.Host, strings.Join(r.Preflight, "; "))
+		}
+		if r.Uncommitted != "" {
+			fmt.Printf("skipped host %s with uncommitted changes made by another user:\n%s", r.Host, r.Uncommitted)
+		}
+	}
+
+	if len(rolledBack) > 0 {
+		fmt.Printf("rolled back %d host(s) whose checks regressed: %s\n", len(rolledBack), strings.Join(rolledBack, ", "))
+	}
+
+	return nil
+}
+
+// backupCommand captures the configuration of the named hosts, or of every
+// host when none are named.
+func backupCommand(nc *netconfig.NetConfig, args []string) error {
+	if len(args) == 0 {
+		return nc.BackupNetwork()
+	}
+
+	for _, name := range args {
+		host, err := nc.Host(name)
+		if err != nil {
+			return err
+		}
+
+		path, err := nc.BackupHost(host)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: %s\n", host.HostName, path)
+	}
+
+	return nil
+}
+
+// restoreCommand loads a stored backup onto a host.
+//
+//	netconfig restore <host> [timestamp]
+func restoreCommand(nc *netconfig.NetConfig, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: restore <host> [timestamp]")
+	}
+
+	host, err := nc.Host(args[0])
+	if err != nil {
+		return err
+	}
+
+	var timestamp string
+	if len(args) == 2 {
+		timestamp = args[1]
+	}
+
+	return nc.RestoreHost(host, timestamp)
+}
+
+// historyCommand prints the commit history of a host, marking the commits
+// which were made by netconfig.
+//
+//	netconfig history <host>
+func historyCommand(nc *netconfig.NetConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: history <host>")
+	}
+
+	host, err := nc.Host(args[0])
+	if err != nil {
+		return err
+	}
+
+	records, err := nc.CommitHistory(host)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tTIME\tUSER\tCLIENT\tSOURCE\tRUN\tREVISION\tCOMMENT")
+	for _, r := range records {
+		source := "manual"
+		if r.Netconfig {
+			source = "netconfig"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Sequence, r.Timestamp, r.User, r.Client, source, r.Provenance.RunID, r.Provenance.Revision, r.Comment)
+	}
+
+	return w.Flush()
+}
+
+// scheduleCommand lists or cancels the pending scheduled commits on every
+// host.
+//
+//	netconfig schedule list|cancel
+func scheduleCommand(nc *netconfig.NetConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: schedule list|cancel")
+	}
+
+	switch args[0] {
+	case "list":
+		pending, err := nc.ScheduledCommits()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tPENDING")
+		for _, p := range pending {
+			fmt.Fprintf(w, "%s\t%s\n", p.Host, p.Detail)
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+
+		return err
+	case "cancel":
+		return nc.CancelScheduledCommits()
+	default:
+		return fmt.Errorf("usage: schedule list|cancel")
+	}
+}
+
+// rollbackCommand rolls back the selected hosts, either undoing the commits of
+// a netconfig run or loading a rollback number.
+//
+//	netconfig rollback <run-id>|<N>
+func rollbackCommand(nc *netconfig.NetConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rollback <run-id>|<N>")
+	}
+
+	var err error
+	if rollback, atoiErr := strconv.Atoi(args[0]); atoiErr == nil {
+		err = nc.RollbackNetwork("", rollback)
+	} else {
+		err = nc.RollbackNetwork(args[0], 0)
+	}
+
+	report := nc.Report()
+	if printErr := printReport(report); printErr != nil {
+		return printErr
+	}
+
+	var committed, skipped []string
+	for _, r := range report.Hosts {
+		if r.Committed {
+			committed = append(committed, r.Host)
+		}
+		if r.Skipped {
+			skipped = append(skipped, r.Host)
+		}
+	}
+
+	if len(committed) > 0 {
+		fmt.Printf("rolled back %d host(s): %s\n", len(committed), strings.Join(committed, ", "))
+	}
+
+	if len(skipped) > 0 {
+		fmt.Printf("skipped %d host(s) without a commit from run %s: %s\n", len(skipped), args[0], strings.Join(skipped, ", "))
+	}
+
+	return err
+}
+
+// testCommand compares the rendered configuration of the test hosts with their
+// golden files, or regenerates the golden files when update is set.
+//
+//	netconfig [-update] test
+func testCommand(nc *netconfig.NetConfig, update bool) error {
+	results, err := nc.TestGolden(update)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range results {
+		switch {
+		case r.Updated:
+			fmt.Printf("UPDATED %s: %s\n", r.Host, r.Path)
+		case r.Missing:
+			failed++
+			fmt.Printf("FAIL    %s: missing golden file %s, run with -update to create it\n", r.Host, r.Path)
+		case r.Diff != "":
+			failed++
+			fmt.Printf("FAIL    %s\n%s\n", r.Host, r.Diff)
+		default:
+			fmt.Printf("PASS    %s\n", r.Host)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts did not match their golden files", failed, len(results))
+	}
+
+	return nil
+}
+
+// verifyTopologyCommand compares the LLDP neighbors of the selected hosts with
+// the topology, printing the result of each link as a table or as JSON.
+//
+//	netconfig [-json] verify-topology
+func verifyTopologyCommand(nc *netconfig.NetConfig, asJSON bool) error {
+	checks, err := nc.VerifyTopology()
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(checks); encErr != nil {
+			return encErr
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOST\tINTERFACE\tSTATUS\tEXPECTED\tNEIGHBOR")
+		for _, c := range checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Host, c.Interface, c.Status, c.Expected, c.Neighbor)
+		}
+		if flushErr := w.Flush(); flushErr != nil {
+			return flushErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, c := range checks {
+		if c.Status != netconfig.LinkOK {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links did not match the topology", failed, len(checks))
+	}
+
+	return nil
+}
@@ -59,31 +59,80 @@ func init() {
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command and returns the exit code of the process, so that the
+// deferred calls, such as the flush of the spans, are made before it exits.
+func run() int {
 	logger := util.NewLogger()
 
 	cfg, err := loadConfig()
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "failed to load config file", "err", err)
-		os.Exit(1)
+		return 1
 	}
 
 	shutdownTracer, err := installOpenTelemetryTracer(cfg, logger)
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "error initialising tracer", "err", err)
-		os.Exit(1)
+		return 1
 	}
 	defer shutdownTracer()
 
-	nc, err := netconfig.New(*cfg, logger)
+	serveMetrics(cfg.Metrics, logger)
+
+	// The serve command builds a NetConfig for each request.
+	if flag.Arg(0) == "serve" {
+		err = serveCommand(cfg, logger)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "command failed", "err", err)
+			return 1
+		}
+		return 0
+	}
+
+	// The reconcile command builds a NetConfig each time the data changes.
+	if flag.Arg(0) == "reconcile" {
+		err = reconcileCommand(cfg, logger)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "command failed", "err", err)
+			return 1
+		}
+		return 0
+	}
+
+	// The test command renders the test hosts from the data directory, and
+	// does not need the inventory.
+	newNetConfig := netconfig.New
+	if flag.Arg(0) == "test" {
+		newNetConfig = netconfig.NewForTestHosts
+	}
+
+	nc, err := newNetConfig(*cfg, logger)
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "failed to get new NetConfig", "err", err)
+		return 1
+	}
+
+	if cfg.Interactive {
+		nc.SetApprover(&promptApprover{})
+	}
+
+	err = runCommand(nc, cfg, flag.Args())
+
+	// The metrics are exported whether or not the command succeeded, so that
+	// failures are recorded.
+	if exportErr := exportMetrics(cfg.Metrics); exportErr != nil {
+		_ = level.Error(logger).Log("msg", "failed to export metrics", "err", exportErr)
 	}
 
-	err = nc.ConfigureNetwork()
 	if err != nil {
-		_ = level.Error(logger).Log("msg", "failed to configure netork", "err", err)
+		_ = level.Error(logger).Log("msg", "command failed", "err", err)
+		return 1
 	}
 
+	return 0
 }
 
 func loadConfig() (*netconfig.Config, error) {
@@ -136,6 +185,11 @@ func loadConfig() (*netconfig.Config, error) {
 	// flagext.IgnoredFlag(flag.CommandLine, diffOption, "Show the diff")
 	flag.Parse()
 
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -187,7 +241,6 @@ func installOpenTelemetryTracer(config *netconfig.Config, logger log.Logger) (fu
 		defer cancel()
 		if err := tracerProvider.Shutdown(ctx); err != nil {
 			_ = level.Error(logger).Log("msg", "OpenTelemetry trace provider failed to shutdown", "err", err)
-			os.Exit(1)
 		}
 	}
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// metricsHandler returns the handler of the metrics of the runs, along with
+// the Go runtime and process metrics of the long running process.
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{netconfig.Registry, prometheus.DefaultGatherer}, promhttp.HandlerOpts{})
+}
+
+// serveMetrics serves the metrics on the listen address in the background.
+func serveMetrics(cfg netconfig.MetricsConfig, logger log.Logger) {
+	if cfg.ListenAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler())
+
+	go func() {
+		_ = level.Info(logger).Log("msg", "serving metrics", "address", cfg.ListenAddress)
+
+		// nolint: gosec
+		err := http.ListenAndServe(cfg.ListenAddress, mux)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "failed to serve metrics", "err", err)
+		}
+	}()
+}
+
+// exportMetrics writes the metrics of the run to the textfile and pushes them
+// to the Pushgateway, when configured.
+func exportMetrics(cfg netconfig.MetricsConfig) error {
+	if cfg.Textfile != "" {
+		err := prometheus.WriteToTextfile(cfg.Textfile, netconfig.Registry)
+		if err != nil {
+			return errors.Wrap(err, "failed to write metrics textfile")
+		}
+	}
+
+	if cfg.Pushgateway != "" {
+		err := push.New(cfg.Pushgateway, cfg.Job).Gatherer(netconfig.Registry).Push()
+		if err != nil {
+			return errors.Wrap(err, "failed to push metrics")
+		}
+	}
+
+	return nil
+}
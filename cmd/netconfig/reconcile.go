@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// reconcileCommand pushes the hosts whose rendered configuration changed since
+// it was last applied, each time the revision of the data directory changes.
+// Hosts which failed are retried at the next interval.  With a zero interval,
+// the network is reconciled once.
+//
+//	netconfig reconcile
+//	netconfig -dry-run -reconcile.interval 0 reconcile
+func reconcileCommand(cfg *netconfig.Config, logger log.Logger) error {
+	if cfg.StateFile == "" {
+		return fmt.Errorf("reconcile requires -state-file to record the configuration applied to each host")
+	}
+
+	inv, err := inventory.NewLDAPInventory(cfg.Inventory, logger)
+	if err != nil {
+		return err
+	}
+
+	c := *cfg
+	c.Commit = !cfg.DryRun
+
+	if cfg.Reconcile.Interval <= 0 {
+		return reconcile(c, inv, logger)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(cfg.Reconcile.Interval)
+	defer ticker.Stop()
+
+	var (
+		last   string
+		failed bool
+	)
+
+	for {
+		rev, err := netconfig.DataRevision(cfg.Data.Directory)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "failed to read data revision", "err", err)
+		} else if rev != last || failed {
+			_ = level.Info(logger).Log("msg", "data changed", "revision", rev, "previous", last)
+
+			err = reconcile(c, inv, logger)
+			if err != nil {
+				_ = level.Error(logger).Log("msg", "reconcile failed", "err", err)
+			}
+
+			last = rev
+			failed = err != nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-sig:
+			_ = level.Info(logger).Log("msg", "shutting down")
+			return nil
+		}
+	}
+}
+
+// reconcile builds a NetConfig from the current data and inventory, and
+// reconciles the network with it, saving the state of the applied hosts.
+func reconcile(cfg netconfig.Config, inv inventory.Inventory, logger log.Logger) error {
+	state, err := netconfig.LoadState(cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	nc, err := netconfig.NewWithInventory(cfg, inv, logger)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]netconfig.HostState{}
+	for h, s := range state.Hosts {
+		previous[h] = s
+	}
+
+	result, err := nc.Reconcile(state)
+
+	if !cfg.DryRun {
+		if saveErr := state.Save(cfg.StateFile); saveErr != nil {
+			_ = level.Error(logger).Log("msg", "failed to save state", "err", saveErr)
+		}
+	}
+
+	printReconcile(cfg, previous, result)
+
+	if exportErr := exportMetrics(cfg.Metrics); exportErr != nil {
+		_ = level.Error(logger).Log("msg", "failed to export metrics", "err", exportErr)
+	}
+
+	return err
+}
+
+// printReconcile prints the hosts which changed, with the revision previously
+// applied to each.
+func printReconcile(cfg netconfig.Config, previous map[string]netconfig.HostState, result netconfig.ReconcileResult) {
+	failed := map[string]bool{}
+	for _, r := range result.Report.Hosts {
+		failed[r.Host] = r.Err != nil
+	}
+
+	for _, h := range result.Changed {
+		verb := "pushed"
+		switch {
+		case cfg.DryRun:
+			verb = "would push"
+		case failed[h]:
+			verb = "failed to push"
+		}
+
+		since := "never applied"
+		if s, ok := previous[h]; ok && s.Revision != "" {
+			since = "last applied " + s.Revision
+		}
+
+		fmt.Printf("%s %s (%s)\n", verb, h, since)
+	}
+
+	fmt.Printf("%d changed, %d unchanged at revision %s\n", len(result.Changed), len(result.Unchanged), result.Revision)
+}
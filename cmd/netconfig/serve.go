@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/xaque208/znet/modules/inventory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+	"github.com/xaque208/netconfig/pkg/service"
+)
+
+// serveCommand runs the gRPC service and its JSON gateway until interrupted.
+//
+//	netconfig serve
+func serveCommand(cfg *netconfig.Config, logger log.Logger) error {
+	serverTLS, clientTLS, err := service.TLSConfig(cfg.Serve)
+	if err != nil {
+		return errors.Wrap(err, "invalid serve configuration")
+	}
+
+	if serverTLS == nil {
+		_ = level.Warn(logger).Log("msg", "serving without TLS, and without authenticating the calls which do not touch devices")
+	}
+
+	inv, err := inventory.NewLDAPInventory(cfg.Inventory, logger)
+	if err != nil {
+		return err
+	}
+
+	srv := service.NewServer(*cfg, func(c netconfig.Config) (*netconfig.NetConfig, error) {
+		return netconfig.NewWithInventory(c, inv, logger)
+	}, logger)
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(srv.UnaryInterceptor),
+		grpc.StreamInterceptor(srv.StreamInterceptor),
+	}
+	if serverTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(serverTLS)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	service.RegisterNetConfigServer(grpcServer, srv)
+
+	lis, err := net.Listen("tcp", cfg.Serve.GRPCListenAddress)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for grpc")
+	}
+
+	go func() {
+		_ = level.Info(logger).Log("msg", "serving grpc", "address", lis.Addr().String(), "tls", serverTLS != nil)
+
+		if err := grpcServer.Serve(lis); err != nil {
+			_ = level.Error(logger).Log("msg", "grpc server failed", "err", err)
+		}
+	}()
+
+	// The gateway calls the gRPC listener as any other client.
+	// nolint: staticcheck
+	dialOpt := grpc.WithInsecure()
+	if clientTLS != nil {
+		dialOpt = grpc.WithTransportCredentials(credentials.NewTLS(clientTLS))
+	}
+
+	conn, err := grpc.Dial(lis.Addr().String(), dialOpt)
+	if err != nil {
+		return errors.Wrap(err, "failed to dial grpc")
+	}
+	defer conn.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", service.NewGateway(service.NewNetConfigClient(conn)))
+	mux.Handle("/metrics", metricsHandler())
+
+	httpServer := &http.Server{
+		Addr:              cfg.Serve.HTTPListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         serverTLS,
+	}
+
+	go func() {
+		_ = level.Info(logger).Log("msg", "serving http", "address", cfg.Serve.HTTPListenAddress, "tls", serverTLS != nil)
+
+		var err error
+		if serverTLS != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			_ = level.Error(logger).Log("msg", "http server failed", "err", err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	_ = level.Info(logger).Log("msg", "shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_ = httpServer.Shutdown(ctx)
+	grpcServer.GracefulStop()
+
+	return nil
+}
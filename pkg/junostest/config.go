@@ -0,0 +1,438 @@
+package junostest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// statement is a single leaf statement of a configuration, along with the
+// hierarchy in which it was found.  The last element of path is the leaf
+// itself, without the terminating semicolon.
+type statement struct {
+	path []string
+}
+
+// key identifies the statement regardless of how its hierarchy was written,
+// so that "system { host-name a; }" and "set system host-name a" are equal.
+func (s statement) key() string {
+	return strings.Join(strings.Fields(strings.Join(s.path, " ")), " ")
+}
+
+// configuration is an ordered set of statements.  The model has no knowledge
+// of the Junos schema, so a merge is the union of the statements, and a
+// statement is only replaced by an override or a delete.
+type configuration struct {
+	statements []statement
+}
+
+func (c *configuration) clone() *configuration {
+	n := &configuration{statements: make([]statement, len(c.statements))}
+	copy(n.statements, c.statements)
+	return n
+}
+
+func (c *configuration) has(key string) bool {
+	for _, s := range c.statements {
+		if s.key() == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// merge adds the statements which are not already present.
+func (c *configuration) merge(statements []statement) {
+	for _, s := range statements {
+		if !c.has(s.key()) {
+			c.statements = append(c.statements, s)
+		}
+	}
+}
+
+// delete removes every statement at or below the given hierarchy.
+func (c *configuration) delete(prefix string) {
+	prefix = strings.Join(strings.Fields(prefix), " ")
+
+	kept := c.statements[:0]
+	for _, s := range c.statements {
+		k := s.key()
+		if k == prefix || strings.HasPrefix(k, prefix+" ") {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	c.statements = kept
+}
+
+func (c *configuration) equal(o *configuration) bool {
+	if len(c.statements) != len(o.statements) {
+		return false
+	}
+
+	for _, s := range c.statements {
+		if !o.has(s.key()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// text renders the configuration in the curly brace format.
+func (c *configuration) text() string {
+	root := &node{}
+	for _, s := range c.statements {
+		root.add(s.path)
+	}
+
+	var buf bytes.Buffer
+	root.write(&buf, 0)
+
+	return buf.String()
+}
+
+type node struct {
+	name     string
+	leaf     bool
+	children []*node
+}
+
+func (n *node) add(path []string) {
+	if len(path) == 1 {
+		n.children = append(n.children, &node{name: path[0], leaf: true})
+		return
+	}
+
+	for _, child := range n.children {
+		if !child.leaf && child.name == path[0] {
+			child.add(path[1:])
+			return
+		}
+	}
+
+	child := &node{name: path[0]}
+	n.children = append(n.children, child)
+	child.add(path[1:])
+}
+
+func (n *node) write(w io.Writer, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	for _, child := range n.children {
+		if child.leaf {
+			fmt.Fprintf(w, "%s%s;\n", indent, child.name)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s%s {\n", indent, child.name)
+		child.write(w, depth+1)
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+}
+
+// diff renders the difference between two configurations in the style of
+// "show | compare", grouping the changed statements by their hierarchy.
+func diff(from, to *configuration) string {
+	type change struct {
+		sign string
+		leaf string
+	}
+
+	var (
+		order   []string
+		changes = map[string][]change{}
+	)
+
+	record := func(s statement, sign string) {
+		parent := strings.Join(s.path[:len(s.path)-1], " ")
+		if _, ok := changes[parent]; !ok {
+			order = append(order, parent)
+		}
+		changes[parent] = append(changes[parent], change{sign: sign, leaf: s.path[len(s.path)-1]})
+	}
+
+	for _, s := range from.statements {
+		if !to.has(s.key()) {
+			record(s, "-")
+		}
+	}
+
+	for _, s := range to.statements {
+		if !from.has(s.key()) {
+			record(s, "+")
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, parent := range order {
+		if parent == "" {
+			buf.WriteString("[edit]\n")
+		} else {
+			fmt.Fprintf(&buf, "[edit %s]\n", parent)
+		}
+
+		for _, c := range changes[parent] {
+			fmt.Fprintf(&buf, "%s  %s;\n", c.sign, c.leaf)
+		}
+	}
+
+	return buf.String()
+}
+
+// parseText parses a configuration in the curly brace format.
+func parseText(text string) ([]statement, error) {
+	var (
+		statements []statement
+		stack      []string
+		words      []string
+	)
+
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tokens {
+		switch t {
+		case "{":
+			if len(words) == 0 {
+				return nil, fmt.Errorf("syntax error, unexpected '{'")
+			}
+			stack = append(stack, strings.Join(words, " "))
+			words = nil
+		case "}":
+			if len(words) > 0 {
+				return nil, fmt.Errorf("syntax error, expecting ';' after %q", strings.Join(words, " "))
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("syntax error, unexpected '}'")
+			}
+			stack = stack[:len(stack)-1]
+		case ";":
+			if len(words) == 0 {
+				continue
+			}
+			path := append(append([]string{}, stack...), strings.Join(words, " "))
+			statements = append(statements, statement{path: path})
+			words = nil
+		default:
+			// Load directives are accepted and ignored.
+			if t == "replace:" || t == "merge:" {
+				continue
+			}
+			words = append(words, t)
+		}
+	}
+
+	if len(words) > 0 {
+		return nil, fmt.Errorf("syntax error, expecting ';' or '{' after %q", strings.Join(words, " "))
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("syntax error, missing '}'")
+	}
+
+	return statements, nil
+}
+
+// tokenize splits the curly brace format into words, braces and semicolons,
+// removing comments.  Quoted strings and bracketed lists are kept as single
+// words.
+func tokenize(text string) ([]string, error) {
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == '#' && word.Len() == 0:
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error, unterminated comment")
+			}
+			i += end + 3
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error, unterminated string")
+			}
+			word.WriteString(text[i : i+end+2])
+			i += end + 1
+		case c == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error, unterminated list")
+			}
+			flush()
+			tokens = append(tokens, "[ "+strings.Join(strings.Fields(text[i+1:i+end]), " ")+" ]")
+			i += end
+		case c == '{' || c == '}' || c == ';':
+			flush()
+			tokens = append(tokens, string(c))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// setCommand is a single line of the set format.
+type setCommand struct {
+	delete bool
+	words  []string
+}
+
+// parseSet parses a configuration in the set format.
+func parseSet(text string) ([]setCommand, error) {
+	var commands []setCommand
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens, err := tokenize(line)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tokens) < 2 {
+			return nil, fmt.Errorf("syntax error: %s", line)
+		}
+
+		switch tokens[0] {
+		case "set":
+			commands = append(commands, setCommand{words: tokens[1:]})
+		case "delete":
+			commands = append(commands, setCommand{delete: true, words: tokens[1:]})
+		default:
+			return nil, fmt.Errorf("syntax error: %s", line)
+		}
+	}
+
+	return commands, nil
+}
+
+// statement returns the statement set by the command.  The hierarchy of a set
+// command is not known, so the final two words are taken as the leaf.
+func (s setCommand) statement() statement {
+	if len(s.words) <= 2 {
+		return statement{path: []string{strings.Join(s.words, " ")}}
+	}
+
+	n := len(s.words) - 2
+	path := append(append([]string{}, s.words[:n]...), strings.Join(s.words[n:], " "))
+
+	return statement{path: path}
+}
+
+// xmlElement is a generic element of a configuration in the XML format.
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Text     string       `xml:",chardata"`
+	Children []xmlElement `xml:",any"`
+}
+
+func (e xmlElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// parseXML parses a configuration in the XML format, returning the statements
+// to merge and the hierarchies to delete.
+func parseXML(text string) ([]statement, []string, error) {
+	var root xmlElement
+	err := xml.Unmarshal([]byte(text), &root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statements, deletes := xmlStatements(root)
+
+	return statements, deletes, nil
+}
+
+// xmlStatements returns the statements to merge and the hierarchies to delete
+// from the children of a <configuration> element.
+func xmlStatements(root xmlElement) ([]statement, []string) {
+	var (
+		statements []statement
+		deletes    []string
+	)
+
+	var walk func(e xmlElement, parent string, path []string)
+	walk = func(e xmlElement, parent string, path []string) {
+		name := e.XMLName.Local
+
+		var nameValue string
+		var children []xmlElement
+		for _, c := range e.Children {
+			if c.XMLName.Local == "name" && len(c.Children) == 0 {
+				nameValue = strings.TrimSpace(c.Text)
+				continue
+			}
+			children = append(children, c)
+		}
+
+		label := name
+		switch {
+		case nameValue != "" && parent == name+"s":
+			label = nameValue
+		case nameValue != "":
+			label = name + " " + nameValue
+		case len(children) == 0 && strings.TrimSpace(e.Text) != "":
+			label = name + " " + strings.TrimSpace(e.Text)
+		}
+
+		here := append(append([]string{}, path...), label)
+
+		if e.attr("delete") == "delete" || e.attr("operation") == "delete" {
+			deletes = append(deletes, strings.Join(here, " "))
+			return
+		}
+
+		if len(children) == 0 {
+			statements = append(statements, statement{path: here})
+			return
+		}
+
+		for _, c := range children {
+			walk(c, name, here)
+		}
+	}
+
+	for _, c := range root.Children {
+		walk(c, root.XMLName.Local, nil)
+	}
+
+	return statements, deletes
+}
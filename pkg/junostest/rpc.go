@@ -0,0 +1,574 @@
+package junostest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rpcRequest is a NETCONF <rpc> carrying a single operation.
+type rpcRequest struct {
+	XMLName   xml.Name
+	MessageID string     `xml:"message-id,attr"`
+	Operation xmlElement `xml:",any"`
+}
+
+// rpcError is returned by a handler to produce an <rpc-error> reply.
+type rpcError struct {
+	message string
+}
+
+func (e *rpcError) Error() string {
+	return e.message
+}
+
+func errorf(format string, args ...interface{}) *rpcError {
+	return &rpcError{message: fmt.Sprintf(format, args...)}
+}
+
+const (
+	replyOK = "<ok/>"
+)
+
+func (s *Server) handleRPC(sess *session, msg string) (string, bool) {
+	var req rpcRequest
+	err := xml.Unmarshal([]byte(msg), &req)
+	if err != nil {
+		return rpcReply("", errorReply(fmt.Sprintf("invalid rpc: %s", err))), false
+	}
+
+	op := req.Operation
+	name := op.XMLName.Local
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.rpcs = append(s.rpcs, name)
+
+	// The private candidate of a session stands in for the shared candidate
+	// during its rpcs.  A shared candidate without changes follows the commits
+	// made from a private candidate.
+	if sess.private != nil {
+		shared, running := s.candidate, s.rollbacks[0]
+		s.candidate = sess.private
+
+		defer func() {
+			if sess.private != nil {
+				sess.private = s.candidate
+			}
+
+			s.candidate = shared
+			if s.rollbacks[0] != running && shared.equal(running) {
+				s.candidate = s.rollbacks[0].clone()
+			}
+		}()
+	}
+
+	var (
+		data         string
+		handlerErr   *rpcError
+		closeSession bool
+	)
+
+	switch name {
+	case "get-software-information":
+		data = s.softwareInformation()
+	case "open-configuration":
+		data, handlerErr = s.openConfiguration(sess, op)
+	case "close-configuration":
+		sess.private = nil
+		data = replyOK
+	case "lock-configuration":
+		data, handlerErr = s.lock(sess)
+	case "unlock-configuration":
+		data, handlerErr = s.unlock(sess)
+	case "load-configuration":
+		data, handlerErr = s.loadConfiguration(sess, op)
+	case "get-configuration":
+		data, handlerErr = s.getConfiguration(op)
+	case "commit-configuration":
+		data, handlerErr = s.commitConfiguration(sess, op)
+	case "discard-changes":
+		s.candidate = s.rollbacks[0].clone()
+		data = replyOK
+	case "get-commit-information":
+		data = s.commitInformation()
+	case "clear-system-commit":
+		s.scheduled = nil
+		data = replyOK
+	case "command":
+		data, handlerErr = s.command(op)
+	case "close-session":
+		data = replyOK
+		closeSession = true
+	default:
+		handlerErr = errorf("syntax error, unknown rpc %s", name)
+	}
+
+	if handlerErr != nil {
+		return rpcReply(req.MessageID, errorReply(handlerErr.message)), closeSession
+	}
+
+	return rpcReply(req.MessageID, data), closeSession
+}
+
+func rpcReply(messageID, data string) string {
+	return fmt.Sprintf(`<rpc-reply xmlns="%s" message-id="%s">%s</rpc-reply>`, netconfBaseNS, messageID, data)
+}
+
+func errorReply(message string) string {
+	return fmt.Sprintf("<rpc-error><error-type>application</error-type><error-tag>operation-failed</error-tag>"+
+		"<error-severity>error</error-severity><error-message>%s</error-message></rpc-error>", escape(message))
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func (s *Server) softwareInformation() string {
+	if len(s.RoutingEngines) > 1 {
+		var buf bytes.Buffer
+
+		buf.WriteString("<multi-routing-engine-results>")
+		for _, re := range s.RoutingEngines {
+			fmt.Fprintf(&buf, "<multi-routing-engine-item><re-name>%s</re-name>%s</multi-routing-engine-item>", escape(re), s.singleSoftwareInformation())
+		}
+		buf.WriteString("</multi-routing-engine-results>")
+
+		return buf.String()
+	}
+
+	return s.singleSoftwareInformation()
+}
+
+func (s *Server) singleSoftwareInformation() string {
+	return fmt.Sprintf("<software-information><host-name>%s</host-name><product-model>%s</product-model>"+
+		"<package-information><name>junos</name><comment>JUNOS Software Release [%s]</comment></package-information>"+
+		"</software-information>", escape(s.Hostname), escape(strings.ToLower(s.Model)), escape(s.Version))
+}
+
+func (s *Server) lock(sess *session) (string, *rpcError) {
+	if s.lockedBy != 0 && s.lockedBy != sess.id {
+		return "", errorf("configuration database locked by: %s using netconf (pid %d)", sess.user, s.lockedBy)
+	}
+
+	if s.lockedBy == 0 && !s.candidate.equal(s.rollbacks[0]) {
+		return "", errorf("configuration database modified")
+	}
+
+	s.lockedBy = sess.id
+
+	return replyOK, nil
+}
+
+func (s *Server) unlock(sess *session) (string, *rpcError) {
+	if s.lockedBy != sess.id {
+		return "", errorf("configuration database not locked")
+	}
+
+	s.releaseLocked()
+
+	return replyOK, nil
+}
+
+// releaseLocked releases the exclusive lock, discarding any uncommitted
+// changes made while it was held.  The caller must hold the mutex.
+func (s *Server) releaseLocked() {
+	s.lockedBy = 0
+	s.candidate = s.rollbacks[0].clone()
+}
+
+// openConfiguration opens a private candidate for the session, which may only
+// be done while the shared candidate has no uncommitted changes.
+func (s *Server) openConfiguration(sess *session, op xmlElement) (string, *rpcError) {
+	if len(op.Children) == 0 || op.Children[0].XMLName.Local != "private" {
+		return "", errorf("only private configuration databases are supported")
+	}
+
+	if err := s.writable(sess); err != nil {
+		return "", err
+	}
+
+	if !s.candidate.equal(s.rollbacks[0]) {
+		return "", errorf("shared configuration database modified")
+	}
+
+	sess.private = s.rollbacks[0].clone()
+
+	return replyOK, nil
+}
+
+// writable returns an error when another session holds the lock.
+func (s *Server) writable(sess *session) *rpcError {
+	if s.lockedBy != 0 && s.lockedBy != sess.id {
+		return errorf("configuration database locked by another session")
+	}
+
+	return nil
+}
+
+func (s *Server) loadConfiguration(sess *session, op xmlElement) (string, *rpcError) {
+	if err := s.writable(sess); err != nil {
+		return "", err
+	}
+
+	if r := op.attr("rollback"); r != "" {
+		n, err := strconv.Atoi(r)
+		if err != nil || n < 0 || n >= len(s.rollbacks) {
+			return "", errorf("rollback %s does not exist", r)
+		}
+
+		s.candidate = s.rollbacks[n].clone()
+
+		return loadResults(), nil
+	}
+
+	action := op.attr("action")
+	if action == "" {
+		action = "merge"
+	}
+
+	var body xmlElement
+	if len(op.Children) > 0 {
+		body = op.Children[0]
+	}
+
+	switch op.attr("format") {
+	case "text":
+		if action == "set" {
+			return s.loadSet(body.Text)
+		}
+
+		statements, err := parseText(body.Text)
+		if err != nil {
+			return "", errorf("%s", err)
+		}
+
+		s.apply(action, statements)
+	case "xml", "":
+		statements, deletes := xmlStatements(body)
+
+		for _, d := range deletes {
+			s.candidate.delete(d)
+		}
+		s.apply(action, statements)
+	default:
+		return "", errorf("unsupported format %s", op.attr("format"))
+	}
+
+	return loadResults(), nil
+}
+
+func (s *Server) apply(action string, statements []statement) {
+	if action == "override" {
+		s.candidate = &configuration{}
+	}
+
+	s.candidate.merge(statements)
+}
+
+func (s *Server) loadSet(text string) (string, *rpcError) {
+	commands, err := parseSet(text)
+	if err != nil {
+		return "", errorf("%s", err)
+	}
+
+	for _, c := range commands {
+		if c.delete {
+			s.candidate.delete(strings.Join(c.words, " "))
+			continue
+		}
+
+		s.candidate.merge([]statement{c.statement()})
+	}
+
+	return loadResults(), nil
+}
+
+func loadResults() string {
+	return "<load-configuration-results><ok/></load-configuration-results>"
+}
+
+func (s *Server) getConfiguration(op xmlElement) (string, *rpcError) {
+	if op.attr("compare") == "rollback" {
+		n, err := strconv.Atoi(op.attr("rollback"))
+		if err != nil || n < 0 || n >= len(s.rollbacks) {
+			return "", errorf("rollback %s does not exist", op.attr("rollback"))
+		}
+
+		return fmt.Sprintf("<configuration-information><configuration-output>%s</configuration-output></configuration-information>",
+			escape(diff(s.rollbacks[n], s.candidate))), nil
+	}
+
+	if op.attr("format") != "text" {
+		return "", errorf("only the text format is supported")
+	}
+
+	config := s.candidate
+	if op.attr("database") == "committed" {
+		config = s.rollbacks[0]
+	}
+
+	return fmt.Sprintf("<configuration-text>%s</configuration-text>", escape(config.text())), nil
+}
+
+func (s *Server) commitConfiguration(sess *session, op xmlElement) (string, *rpcError) {
+	var (
+		check, confirmed bool
+		synchronize      bool
+		timeout          = 10
+		at, log          string
+	)
+
+	for _, c := range op.Children {
+		switch c.XMLName.Local {
+		case "check":
+			check = true
+		case "synchronize":
+			synchronize = true
+		case "confirmed":
+			confirmed = true
+		case "confirm-timeout":
+			n, err := strconv.Atoi(strings.TrimSpace(c.Text))
+			if err != nil {
+				return "", errorf("invalid confirm-timeout %s", c.Text)
+			}
+			timeout = n
+		case "at-time":
+			at = strings.TrimSpace(c.Text)
+		case "log":
+			log = c.Text
+		}
+	}
+
+	if err := s.writable(sess); err != nil {
+		return "", err
+	}
+
+	if s.CommitCheck != nil {
+		if err := s.CommitCheck(s.candidate.text()); err != nil {
+			return commitFailure(err.Error()), nil
+		}
+	}
+
+	if check {
+		return commitSuccess(), nil
+	}
+
+	if at != "" {
+		s.scheduled = append(s.scheduled, ScheduledCommit{User: sess.user, At: at, Log: log, candidate: s.candidate.clone()})
+		return commitSuccess(), nil
+	}
+
+	s.commit(sess.user, log, confirmed)
+
+	if s.confirm != nil {
+		s.confirm.Stop()
+		s.confirm = nil
+	}
+
+	if confirmed {
+		s.confirm = time.AfterFunc(time.Duration(timeout)*s.ConfirmUnit, s.confirmTimeout)
+	}
+
+	if len(s.RoutingEngines) > 1 {
+		return s.commitEngines(synchronize), nil
+	}
+
+	return commitSuccess(), nil
+}
+
+// commitEngines returns the results of a commit on each routing engine.  The
+// backup routing engines are only committed when synchronized, and fail when
+// unsynced.
+func (s *Server) commitEngines(synchronize bool) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("<commit-results>")
+	for i, re := range s.RoutingEngines {
+		switch {
+		case i > 0 && !synchronize:
+			continue
+		case i > 0 && containsString(s.UnsyncedEngines, re):
+			fmt.Fprintf(&buf, "<routing-engine><name>%s</name><rpc-error><error-severity>error</error-severity>"+
+				"<error-message>commit failed on %s</error-message></rpc-error></routing-engine>", escape(re), escape(re))
+		default:
+			fmt.Fprintf(&buf, "<routing-engine><name>%s</name><commit-success/></routing-engine>", escape(re))
+		}
+	}
+	buf.WriteString("</commit-results>")
+
+	return buf.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commit makes the candidate the running configuration.
+func (s *Server) commit(user, log string, confirmed bool) {
+	s.rollbacks = append([]*configuration{s.candidate.clone()}, s.rollbacks...)
+	if len(s.rollbacks) > maxRollbacks {
+		s.rollbacks = s.rollbacks[:maxRollbacks]
+	}
+
+	s.history = append([]Commit{{User: user, Client: "netconf", Log: log, Time: time.Now().UTC(), Confirmed: confirmed}}, s.history...)
+	if len(s.history) > maxRollbacks {
+		s.history = s.history[:maxRollbacks]
+	}
+}
+
+// confirmTimeout rolls back an unconfirmed commit.
+func (s *Server) confirmTimeout() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.confirm == nil || len(s.rollbacks) < 2 {
+		return
+	}
+	s.confirm = nil
+
+	s.candidate = s.rollbacks[1].clone()
+	s.commit("root", "rollback due to unconfirmed commit", false)
+}
+
+func commitSuccess() string {
+	return "<commit-results><routing-engine><name>re0</name><commit-success/></routing-engine></commit-results>"
+}
+
+func commitFailure(message string) string {
+	return "<commit-results><rpc-error><error-severity>error</error-severity>" +
+		"<error-message>" + escape(message) + "</error-message></rpc-error></commit-results>"
+}
+
+func (s *Server) commitInformation() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("<commit-information>")
+	for i, c := range s.history {
+		fmt.Fprintf(&buf, "<commit-history><sequence-number>%d</sequence-number><user>%s</user><client>%s</client>"+
+			"<date-time>%s</date-time><log>%s</log></commit-history>",
+			i, escape(c.User), escape(c.Client), c.Time.Format("2006-01-02 15:04:05 MST"), escape(c.Log))
+	}
+	buf.WriteString("</commit-information>")
+
+	return buf.String()
+}
+
+func (s *Server) command(op xmlElement) (string, *rpcError) {
+	cmd := strings.Join(strings.Fields(op.Text), " ")
+
+	output, ok := s.commands[cmd]
+	if f, found := s.commandFns[cmd]; found {
+		output, ok = f(s.rollbacks[0].text()), true
+	}
+	if !ok && cmd == "show system commit" {
+		output = s.showSystemCommit()
+		ok = true
+	}
+	if !ok && cmd == "show chassis routing-engine" && len(s.RoutingEngines) > 1 {
+		output, ok = s.showChassisRoutingEngine(), true
+	}
+	if !ok && cmd == "show interfaces terse routing-instance __juniper_private1__" && len(s.RoutingEngines) > 1 {
+		output, ok = s.showInternalInterfaces(), true
+	}
+	if !ok {
+		output, ok = healthyCommands[cmd]
+	}
+
+	if !ok {
+		return "", errorf("syntax error, expecting <command>: %s", cmd)
+	}
+
+	if op.attr("format") == "xml" {
+		return output, nil
+	}
+
+	return fmt.Sprintf("<output>%s</output>", escape(output)), nil
+}
+
+// healthyCommands are the xml outputs of the commands reporting the health of
+// the device, used unless set otherwise: no alarms, a single routing engine
+// and plenty of disk space.
+var healthyCommands = map[string]string{
+	"show chassis alarms": "<alarm-information><alarm-summary><no-active-alarms/></alarm-summary></alarm-information>",
+	"show system alarms":  "<alarm-information><alarm-summary><no-active-alarms/></alarm-summary></alarm-information>",
+	"show chassis routing-engine": "<route-engine-information><route-engine><slot>0</slot>" +
+		"<mastership-state>master</mastership-state><status>OK</status></route-engine></route-engine-information>",
+	"show system storage": "<system-storage-information><filesystem><filesystem-name>/dev/gpt/junos</filesystem-name>" +
+		"<used-percent>20</used-percent><mounted-on>/.mount</mounted-on></filesystem>" +
+		"<filesystem><filesystem-name>/dev/gpt/var</filesystem-name>" +
+		"<used-percent>35</used-percent><mounted-on>/.mount/var</mounted-on></filesystem></system-storage-information>",
+}
+
+// showChassisRoutingEngine returns the routing engines of a device with more
+// than one, the first of which is master.
+func (s *Server) showChassisRoutingEngine() string {
+	var buf bytes.Buffer
+
+	buf.WriteString("<route-engine-information>")
+	for i, re := range s.RoutingEngines {
+		state := "backup"
+		if i == 0 {
+			state = "master"
+		}
+
+		fmt.Fprintf(&buf, "<route-engine><slot>%s</slot><mastership-state>%s</mastership-state><status>OK</status></route-engine>",
+			escape(strings.TrimLeft(re, "abcdefghijklmnopqrstuvwxyz")), state)
+	}
+	buf.WriteString("</route-engine-information>")
+
+	return buf.String()
+}
+
+// showInternalInterfaces returns the internal interface of the routing engine
+// to which sessions connect, with its address, and the master alias address
+// when it is the master.
+func (s *Server) showInternalInterfaces() string {
+	connected := 0
+	for i, re := range s.RoutingEngines {
+		if re == s.ConnectedEngine {
+			connected = i
+		}
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("<interface-information><physical-interface><name>em0</name><logical-interface><name>em0.0</name>" +
+		"<address-family><address-family-name>inet</address-family-name>")
+	if connected == 0 {
+		buf.WriteString("<interface-address><ifa-local>128.0.0.1/2</ifa-local></interface-address>")
+	}
+	fmt.Fprintf(&buf, "<interface-address><ifa-local>128.0.0.%d/2</ifa-local></interface-address>", 4+connected)
+	buf.WriteString("</address-family></logical-interface></physical-interface></interface-information>")
+
+	return buf.String()
+}
+
+func (s *Server) showSystemCommit() string {
+	var buf bytes.Buffer
+
+	for _, sc := range s.scheduled {
+		fmt.Fprintf(&buf, "commit requested by %s via netconf at %s\n", sc.User, sc.At)
+	}
+
+	for i, c := range s.history {
+		fmt.Fprintf(&buf, "%d   %s by %s via %s\n", i, c.Time.Format("2006-01-02 15:04:05 MST"), c.User, c.Client)
+		if c.Log != "" {
+			fmt.Fprintf(&buf, "    %s\n", c.Log)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,446 @@
+// Package junostest provides an in-process NETCONF server over SSH which
+// emulates the Junos RPCs used by netconfig, for use in tests.
+package junostest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scottdware/go-junos"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	msgSeparator   = "]]>]]>"
+	netconfBaseNS  = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	netconfBaseCap = "urn:ietf:params:netconf:base:1.0"
+
+	// maxRollbacks is the number of configurations retained by Junos.
+	maxRollbacks = 50
+)
+
+// Commit is an entry in the commit history of the simulated device.
+type Commit struct {
+	User      string
+	Client    string
+	Log       string
+	Time      time.Time
+	Confirmed bool
+}
+
+// ScheduledCommit is a commit requested to happen at a later time.
+type ScheduledCommit struct {
+	User string
+	At   string
+	Log  string
+
+	candidate *configuration
+}
+
+// Server is a simulated Junos device, reachable over NETCONF on SSH.  The
+// device holds a running configuration, the previous committed
+// configurations, a shared candidate configuration, and the private candidate
+// of each session which opened one.
+type Server struct {
+	// Hostname, Model and Version are reported by <get-software-information>.
+	Hostname string
+	Model    string
+	Version  string
+
+	// CommitCheck, when set, is called with the candidate configuration in
+	// the curly brace format before every commit and commit check.  A
+	// returned error fails the commit.
+	CommitCheck func(config string) error
+
+	// ConfirmUnit is the length of one minute of a commit confirmed timeout,
+	// allowing tests to observe the automatic rollback.
+	ConfirmUnit time.Duration
+
+	// RoutingEngines are the names of the routing engines of a device with
+	// more than one, eg: "re0" and "re1", or the members of a virtual chassis.
+	// The first is the master.  A commit is only made on the backup routing
+	// engines when synchronized, and fails on those in UnsyncedEngines.
+	RoutingEngines  []string
+	UnsyncedEngines []string
+
+	// ConnectedEngine is the routing engine to which sessions connect, the
+	// master when empty.
+	ConnectedEngine string
+
+	listener  net.Listener
+	sshConfig *ssh.ServerConfig
+	wg        sync.WaitGroup
+	conns     map[net.Conn]struct{}
+
+	mtx         sync.Mutex
+	candidate   *configuration
+	rollbacks   []*configuration
+	history     []Commit
+	scheduled   []ScheduledCommit
+	commands    map[string]string
+	commandFns  map[string]func(running string) string
+	rpcs        []string
+	lockedBy    int
+	nextSession int
+	confirm     *time.Timer
+}
+
+// NewServer starts a simulated device with the given running configuration
+// in the curly brace format, listening on a random local port.
+func NewServer(hostname, config string) (*Server, error) {
+	statements, err := parseText(config)
+	if err != nil {
+		return nil, err
+	}
+
+	running := &configuration{}
+	running.merge(statements)
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	sshConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Hostname:    hostname,
+		Model:       "VSRX",
+		Version:     "20.4R3.8",
+		ConfirmUnit: time.Minute,
+		listener:    listener,
+		sshConfig:   sshConfig,
+		candidate:   running.clone(),
+		rollbacks:   []*configuration{running},
+		commands:    map[string]string{},
+		commandFns:  map[string]func(running string) string{},
+		conns:       map[net.Conn]struct{}{},
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address on which the server is listening.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mtx.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.confirm != nil {
+		s.confirm.Stop()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+// Dial opens a Junos session to the server.
+func (s *Server) Dial() (*junos.Junos, error) {
+	config := &ssh.ClientConfig{
+		User:            "netconfig",
+		Auth:            []ssh.AuthMethod{ssh.Password("netconfig")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // nolint: gosec
+	}
+
+	return junos.NewSessionWithConfig(s.Addr(), config)
+}
+
+// Running returns the running configuration in the curly brace format.
+func (s *Server) Running() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.rollbacks[0].text()
+}
+
+// Candidate returns the candidate configuration in the curly brace format.
+func (s *Server) Candidate() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.candidate.text()
+}
+
+// SetCandidate replaces the candidate configuration, as though another user
+// had made uncommitted changes.
+func (s *Server) SetCandidate(config string) error {
+	statements, err := parseText(config)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.candidate = &configuration{}
+	s.candidate.merge(statements)
+
+	return nil
+}
+
+// Locked returns true when the configuration is locked by a session.
+func (s *Server) Locked() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.lockedBy != 0
+}
+
+// Commits returns the commit history, most recent first.
+func (s *Server) Commits() []Commit {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	history := make([]Commit, len(s.history))
+	copy(history, s.history)
+
+	return history
+}
+
+// Scheduled returns the pending scheduled commits.
+func (s *Server) Scheduled() []ScheduledCommit {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	scheduled := make([]ScheduledCommit, len(s.scheduled))
+	copy(scheduled, s.scheduled)
+
+	return scheduled
+}
+
+// ConfirmPending returns true while a commit confirmed is awaiting
+// confirmation.
+func (s *Server) ConfirmPending() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.confirm != nil
+}
+
+// SetCommand sets the output returned for an operational command.  The output
+// is returned as is when the command is requested in xml format.
+func (s *Server) SetCommand(command, output string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.commands[command] = output
+}
+
+// SetCommandFunc sets a function returning the output of an operational
+// command from the running configuration, so that the operational state of
+// the device may follow the changes committed to it.
+func (s *Server) SetCommandFunc(command string, f func(running string) string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.commandFns[command] = f
+}
+
+// RPCs returns the names of the RPCs received by the server, in order.
+func (s *Server) RPCs() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	rpcs := make([]string, len(s.rpcs))
+	copy(rpcs, s.rpcs)
+
+	return rpcs
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	s.mtx.Lock()
+	s.conns[conn] = struct{}{}
+	s.mtx.Unlock()
+
+	defer func() {
+		s.mtx.Lock()
+		delete(s.conns, conn)
+		s.mtx.Unlock()
+
+		conn.Close()
+	}()
+
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, s.sshConfig)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range channelRequests {
+				ok := req.Type == "subsystem" && subsystemName(req.Payload) == "netconf"
+				_ = req.Reply(ok, nil)
+
+				if ok {
+					go s.handleNetconf(channel, sshConn.User())
+				}
+			}
+		}()
+	}
+}
+
+func subsystemName(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+
+	n := binary.BigEndian.Uint32(payload)
+	if int(n) > len(payload)-4 {
+		return ""
+	}
+
+	return string(payload[4 : 4+n])
+}
+
+// session is a single NETCONF session.  A session which opened a private
+// configuration database has its own candidate.
+type session struct {
+	id      int
+	user    string
+	private *configuration
+}
+
+func (s *Server) handleNetconf(channel ssh.Channel, user string) {
+	defer channel.Close()
+
+	s.mtx.Lock()
+	s.nextSession++
+	sess := &session{id: s.nextSession, user: user}
+	s.mtx.Unlock()
+
+	defer s.releaseLock(sess)
+
+	hello := fmt.Sprintf(`<hello xmlns="%s"><capabilities><capability>%s</capability></capabilities><session-id>%d</session-id></hello>`,
+		netconfBaseNS, netconfBaseCap, sess.id)
+
+	err := writeMessage(channel, hello)
+	if err != nil {
+		return
+	}
+
+	reader := bufio.NewReader(channel)
+
+	// The first message from the client is its hello.
+	_, err = readMessage(reader)
+	if err != nil {
+		return
+	}
+
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			return
+		}
+
+		reply, closeSession := s.handleRPC(sess, msg)
+
+		err = writeMessage(channel, reply)
+		if err != nil || closeSession {
+			return
+		}
+	}
+}
+
+func (s *Server) releaseLock(sess *session) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.lockedBy == sess.id {
+		s.releaseLocked()
+	}
+}
+
+func readMessage(r *bufio.Reader) (string, error) {
+	var buf bytes.Buffer
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && buf.Len() > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+
+		buf.WriteByte(b)
+
+		if b == '>' && bytes.HasSuffix(buf.Bytes(), []byte(msgSeparator)) {
+			msg := buf.String()
+			return strings.TrimSpace(strings.TrimSuffix(msg, msgSeparator)), nil
+		}
+	}
+}
+
+func writeMessage(w io.Writer, msg string) error {
+	_, err := io.WriteString(w, msg+msgSeparator+"\n")
+	return err
+}
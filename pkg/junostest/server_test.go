@@ -0,0 +1,200 @@
+package junostest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"github.com/stretchr/testify/require"
+)
+
+var errTelnet = errors.New("telnet is not permitted")
+
+const testConfig = `
+## Last commit: 2022-01-02 15:04:05 UTC by netconfig
+version 20.4R3.8;
+system {
+    host-name router1;
+    services {
+        ssh;
+    }
+}
+`
+
+func TestServerSession(t *testing.T) {
+	s, err := NewServer("router1", testConfig)
+	require.NoError(t, err)
+	defer s.Close()
+
+	session, err := s.Dial()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.Equal(t, "router1", session.Hostname)
+	require.Equal(t, "20.4R3.8", session.Platform[0].Version)
+
+	require.NoError(t, session.Lock())
+	require.True(t, s.Locked())
+
+	require.NoError(t, session.Config([]string{"system { domain-name example.com; }"}, "text", false))
+
+	diff, err := session.Diff(0)
+	require.NoError(t, err)
+	require.Equal(t, "[edit system]\n+  domain-name example.com;\n", diff)
+
+	require.NoError(t, session.CommitCheck())
+	require.NoError(t, session.Commit())
+	require.Contains(t, s.Running(), "domain-name example.com;")
+
+	require.NoError(t, session.Config([]string{"delete system services ssh"}, "set", false))
+	diff, err = session.Diff(0)
+	require.NoError(t, err)
+	require.Equal(t, "[edit system services]\n-  ssh;\n", diff)
+
+	require.NoError(t, session.Unlock())
+	require.False(t, s.Locked())
+	require.Equal(t, s.Running(), s.Candidate())
+
+	history, err := session.CommitHistory()
+	require.NoError(t, err)
+	require.Len(t, history.Entries, 1)
+	require.Equal(t, "netconfig", history.Entries[0].User)
+}
+
+func TestServerLockConflict(t *testing.T) {
+	s, err := NewServer("router1", testConfig)
+	require.NoError(t, err)
+	defer s.Close()
+
+	first, err := s.Dial()
+	require.NoError(t, err)
+	defer first.Close()
+
+	second, err := s.Dial()
+	require.NoError(t, err)
+	defer second.Close()
+
+	require.NoError(t, first.Lock())
+	require.Error(t, second.Lock())
+	require.Error(t, second.Config([]string{"set system domain-name example.com"}, "set", false))
+}
+
+func TestServerPrivateCandidate(t *testing.T) {
+	s, err := NewServer("router1", testConfig)
+	require.NoError(t, err)
+	defer s.Close()
+
+	session, err := s.Dial()
+	require.NoError(t, err)
+	defer session.Close()
+
+	reply, err := session.Session.Exec(netconf.RawMethod("<open-configuration><private/></open-configuration>"))
+	require.NoError(t, err)
+	require.Empty(t, reply.Errors)
+
+	require.NoError(t, session.Config([]string{"set system domain-name example.com"}, "set", false))
+	require.NotContains(t, s.Candidate(), "domain-name")
+
+	diff, err := session.Diff(0)
+	require.NoError(t, err)
+	require.Equal(t, "[edit system]\n+  domain-name example.com;\n", diff)
+
+	require.NoError(t, session.Commit())
+	require.Contains(t, s.Running(), "domain-name example.com;")
+	require.Equal(t, s.Running(), s.Candidate())
+
+	reply, err = session.Session.Exec(netconf.RawMethod("<close-configuration/>"))
+	require.NoError(t, err)
+	require.Empty(t, reply.Errors)
+
+	// A private candidate can not be opened over uncommitted changes.
+	require.NoError(t, s.SetCandidate(testConfig+"snmp { community public; }\n"))
+
+	_, err = session.Session.Exec(netconf.RawMethod("<open-configuration><private/></open-configuration>"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shared configuration database modified")
+}
+
+func TestServerCommitCheckFailure(t *testing.T) {
+	s, err := NewServer("router1", testConfig)
+	require.NoError(t, err)
+	defer s.Close()
+
+	s.CommitCheck = func(config string) error {
+		return errTelnet
+	}
+
+	session, err := s.Dial()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, session.Lock())
+	require.NoError(t, session.Config([]string{"set system services telnet"}, "set", false))
+	require.EqualError(t, session.Commit(), errTelnet.Error())
+	require.NotContains(t, s.Running(), "telnet")
+}
+
+func TestParseText(t *testing.T) {
+	statements, err := parseText(`
+/* a comment */
+interfaces {
+    ge-0/0/0 {
+        description "uplink { to core }";
+        unit 0 {
+            family inet {
+                address 10.0.0.1/31;
+            }
+        }
+    }
+}
+vlans {
+    users {
+        vlan-id 10;
+        interface [ ge-0/0/1.0 ge-0/0/2.0 ];
+    }
+}
+`)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, s := range statements {
+		keys = append(keys, s.key())
+	}
+
+	require.Equal(t, []string{
+		`interfaces ge-0/0/0 description "uplink { to core }"`,
+		"interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/31",
+		"vlans users vlan-id 10",
+		"vlans users interface [ ge-0/0/1.0 ge-0/0/2.0 ]",
+	}, keys)
+
+	for _, bad := range []string{"system { host-name a; ", "system host-name a", "}"} {
+		_, err := parseText(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestParseXML(t *testing.T) {
+	statements, deletes, err := parseXML(`<configuration>
+<interfaces>
+  <interface>
+    <name>ge-0/0/0</name>
+    <description>uplink</description>
+    <unit><name>0</name><family><inet><address><name>10.0.0.1/31</name></address></inet></family></unit>
+  </interface>
+</interfaces>
+<system><services><telnet delete="delete"/></services></system>
+</configuration>`)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, s := range statements {
+		keys = append(keys, s.key())
+	}
+
+	require.Equal(t, []string{
+		"interfaces ge-0/0/0 description uplink",
+		"interfaces ge-0/0/0 unit 0 family inet address 10.0.0.1/31",
+	}, keys)
+	require.Equal(t, []string{"system services telnet"}, deletes)
+}
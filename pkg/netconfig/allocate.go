@@ -0,0 +1,453 @@
+package netconfig
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// Types of pool.
+const (
+	PoolPrefix  = "prefix"
+	PoolAddress = "address"
+	PoolInteger = "integer"
+)
+
+// defaultAllocationsFile is the file of the data directory in which
+// allocations are recorded when none is configured.
+const defaultAllocationsFile = "allocations.yaml"
+
+// pool hands out the values of a range by index.
+type pool interface {
+	// size is the number of values in the pool, capped at the largest int.
+	size() int
+	// value returns the value at index i.
+	value(i int) string
+}
+
+// allocator allocates the values of the pools to keys.  When persisted, each
+// allocation is recorded in the allocations file, so that it is stable across
+// runs, and the file is reloaded under a lock before allocating so that
+// concurrent runs do not hand out the same value twice.  Otherwise the
+// allocations are only kept for the run.  It is safe for concurrent use.
+type allocator struct {
+	mtx         sync.Mutex
+	path        string
+	persist     bool
+	pools       map[string]pool
+	allocations map[string]map[string]string
+
+	// While collecting, keys without an allocation are recorded as pending
+	// and given a placeholder value, to be allocated in order afterwards.
+	collecting bool
+	pending    map[allocationKey]bool
+}
+
+// allocationKey is a key of a pool.
+type allocationKey struct {
+	pool, key string
+}
+
+// loadAllocator parses the pools of the data and loads the allocations file
+// from the data directory.  New allocations are written to the file when
+// persist is set.
+func loadAllocator(dir string, d data.Data, persist bool) (*allocator, error) {
+	file := d.AllocationsFile
+	if file == "" {
+		file = defaultAllocationsFile
+	}
+
+	a := &allocator{
+		path:        filepath.Join(dir, file),
+		persist:     persist,
+		pools:       map[string]pool{},
+		allocations: map[string]map[string]string{},
+		pending:     map[allocationKey]bool{},
+	}
+
+	for _, p := range d.Pools {
+		parsed, err := parsePool(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pool %q", p.Name)
+		}
+		a.pools[p.Name] = parsed
+	}
+
+	if len(a.pools) == 0 {
+		return a, nil
+	}
+
+	err := a.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// reload replaces the allocations with those of the allocations file.
+func (a *allocator) reload() error {
+	buf, err := os.ReadFile(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to read allocations")
+	}
+
+	allocations := map[string]map[string]string{}
+
+	err = yaml.UnmarshalStrict(buf, &allocations)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse allocations file %s", a.path)
+	}
+
+	if allocations == nil {
+		allocations = map[string]map[string]string{}
+	}
+	a.allocations = allocations
+
+	return nil
+}
+
+// allocate returns the value of the pool allocated to the key.  A key without
+// an allocation is given the lowest free value of the pool.
+func (a *allocator) allocate(name, key string) (string, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	p, ok := a.pools[name]
+	if !ok {
+		return "", fmt.Errorf("unknown pool %q", name)
+	}
+
+	if v, ok := a.allocations[name][key]; ok {
+		return v, nil
+	}
+
+	if a.collecting {
+		a.pending[allocationKey{pool: name, key: key}] = true
+		return p.value(0), nil
+	}
+
+	err := a.allocateKeys([]allocationKey{{pool: name, key: key}})
+	if err != nil {
+		return "", err
+	}
+
+	return a.allocations[name][key], nil
+}
+
+// collect starts recording the keys without an allocation as pending, rather
+// than allocating them.
+func (a *allocator) collect() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.collecting = true
+}
+
+// allocatePending stops collecting, and allocates the pending keys ordered by
+// pool and key, so that the values given to new keys do not depend on the
+// order in which they were requested.
+func (a *allocator) allocatePending() error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.collecting = false
+
+	keys := make([]allocationKey, 0, len(a.pending))
+	for k := range a.pending {
+		keys = append(keys, k)
+	}
+	a.pending = map[allocationKey]bool{}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pool != keys[j].pool {
+			return keys[i].pool < keys[j].pool
+		}
+		return keys[i].key < keys[j].key
+	})
+
+	return a.allocateKeys(keys)
+}
+
+// allocateKeys gives each key without an allocation the lowest free value of
+// its pool.  When persisted, the allocations file is locked and reloaded
+// first, so that the allocations of other runs are kept, and saved after.
+// The caller must hold the mutex.
+func (a *allocator) allocateKeys(keys []allocationKey) error {
+	if a.persist {
+		unlock, err := lockDir(filepath.Dir(a.path))
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		err = a.reload()
+		if err != nil {
+			return err
+		}
+
+		err = a.assign(keys)
+		if err == nil {
+			err = a.save()
+		}
+		if err != nil {
+			// The allocations which were not saved are dropped.
+			_ = a.reload()
+		}
+
+		return err
+	}
+
+	return a.assign(keys)
+}
+
+// assign gives each key without an allocation the lowest free value of its
+// pool.
+func (a *allocator) assign(keys []allocationKey) error {
+	for _, k := range keys {
+		allocated := a.allocations[k.pool]
+		if _, ok := allocated[k.key]; ok {
+			continue
+		}
+
+		used := make(map[string]bool, len(allocated))
+		for _, v := range allocated {
+			used[v] = true
+		}
+
+		p := a.pools[k.pool]
+
+		found := false
+		for i := 0; i < p.size(); i++ {
+			v := p.value(i)
+			if used[v] {
+				continue
+			}
+
+			if allocated == nil {
+				allocated = map[string]string{}
+				a.allocations[k.pool] = allocated
+			}
+			allocated[k.key] = v
+			found = true
+
+			break
+		}
+
+		if !found {
+			return fmt.Errorf("pool %q is exhausted", k.pool)
+		}
+	}
+
+	return nil
+}
+
+// save writes the allocations file, replacing it atomically.
+func (a *allocator) save() error {
+	buf, err := yaml.Marshal(a.allocations)
+	if err != nil {
+		return err
+	}
+
+	tmp := a.path + ".tmp"
+
+	err = os.WriteFile(tmp, buf, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write allocations")
+	}
+
+	return os.Rename(tmp, a.path)
+}
+
+// lockDir takes an exclusive lock on the directory, waiting for any other
+// holder, and returns the function which releases it.  The directory rather
+// than the file is locked, since the file is replaced when saved.
+func lockDir(dir string) (func(), error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open allocations directory")
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "failed to lock allocations")
+	}
+
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
+
+// parsePool returns the pool of the configuration.
+func parsePool(p data.Pool) (pool, error) {
+	switch p.Type {
+	case PoolPrefix, PoolAddress:
+		_, ipnet, err := net.ParseCIDR(p.Range)
+		if err != nil {
+			return nil, err
+		}
+
+		ones, bits := ipnet.Mask.Size()
+
+		size := p.Size
+		if p.Type == PoolAddress {
+			size = bits
+		}
+
+		if size < ones || size > bits {
+			return nil, fmt.Errorf("size /%d is not within %s", size, p.Range)
+		}
+
+		return &prefixPool{
+			base:    new(big.Int).SetBytes(normalizeIP(ipnet.IP)),
+			ones:    ones,
+			bits:    bits,
+			length:  size,
+			address: p.Type == PoolAddress,
+		}, nil
+	case PoolInteger:
+		parts := strings.SplitN(p.Range, "-", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("range %q is not of the form \"low-high\"", p.Range)
+		}
+
+		low, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+
+		high, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+
+		if high < low {
+			return nil, fmt.Errorf("range %q is empty", p.Range)
+		}
+
+		return &integerPool{low: low, high: high}, nil
+	default:
+		return nil, fmt.Errorf("unknown pool type %q", p.Type)
+	}
+}
+
+// prefixPool is a CIDR divided into prefixes of the same size, or into single
+// addresses.
+type prefixPool struct {
+	base    *big.Int
+	ones    int
+	bits    int
+	length  int
+	address bool
+}
+
+func (p *prefixPool) size() int {
+	return maxInt(p.length - p.ones)
+}
+
+func (p *prefixPool) value(i int) string {
+	offset := new(big.Int).Lsh(big.NewInt(int64(i)), uint(p.bits-p.length))
+	ip := bigToIP(new(big.Int).Add(p.base, offset), p.bits)
+
+	if p.address {
+		return ip.String()
+	}
+
+	return fmt.Sprintf("%s/%d", ip, p.length)
+}
+
+// integerPool is an inclusive range of integers.
+type integerPool struct {
+	low, high int
+}
+
+func (p *integerPool) size() int {
+	return p.high - p.low + 1
+}
+
+func (p *integerPool) value(i int) string {
+	return strconv.Itoa(p.low + i)
+}
+
+// maxInt returns the number of values of a pool with the given number of bits
+// of index, capped at the largest int.
+func maxInt(bits int) int {
+	if bits >= strconv.IntSize-1 {
+		return int(^uint(0) >> 1)
+	}
+
+	return 1 << uint(bits)
+}
+
+// normalizeIP returns the 4 byte form of an IPv4 address.
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+
+	return ip
+}
+
+// bigToIP returns the address of the given number of bits with the value i.
+func bigToIP(i *big.Int, bits int) net.IP {
+	b := i.Bytes()
+	ip := make(net.IP, bits/8)
+	copy(ip[len(ip)-len(b):], b)
+
+	return ip
+}
+
+// prefixAddress returns the nth address of the prefix, with the length of the
+// prefix, eg: prefixAddress "10.0.0.0/31" 1 returns "10.0.0.1/31".
+func prefixAddress(prefix string, n int) (string, error) {
+	_, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if n < 0 || (bits-ones < strconv.IntSize-1 && n >= 1<<uint(bits-ones)) {
+		return "", fmt.Errorf("address %d is not within %s", n, prefix)
+	}
+
+	base := new(big.Int).SetBytes(normalizeIP(ipnet.IP))
+	ip := bigToIP(new(big.Int).Add(base, big.NewInt(int64(n))), bits)
+
+	return fmt.Sprintf("%s/%d", ip, ones), nil
+}
+
+// Allocate returns the value of the pool allocated to the key, allocating the
+// lowest free value when the key has none.
+func (n *NetConfig) Allocate(pool, key string) (string, error) {
+	return n.allocator.allocate(pool, key)
+}
+
+// templateFuncs returns the functions available to templates.
+func (n *NetConfig) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"allocate":      n.Allocate,
+		"prefixAddress": prefixAddress,
+	}
+}
@@ -0,0 +1,247 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func TestParsePool(t *testing.T) {
+	cases := map[string]struct {
+		pool   data.Pool
+		values []string
+		size   int
+		err    bool
+	}{
+		"prefix": {
+			pool:   data.Pool{Type: PoolPrefix, Range: "10.255.0.0/29", Size: 31},
+			values: []string{"10.255.0.0/31", "10.255.0.2/31", "10.255.0.4/31", "10.255.0.6/31"},
+			size:   4,
+		},
+		"address": {
+			pool:   data.Pool{Type: PoolAddress, Range: "192.0.2.0/30"},
+			values: []string{"192.0.2.0", "192.0.2.1", "192.0.2.2", "192.0.2.3"},
+			size:   4,
+		},
+		"inet6 prefix": {
+			pool:   data.Pool{Type: PoolPrefix, Range: "2001:db8::/126", Size: 127},
+			values: []string{"2001:db8::/127", "2001:db8::2/127"},
+			size:   2,
+		},
+		"integer": {
+			pool:   data.Pool{Type: PoolInteger, Range: "64512-64514"},
+			values: []string{"64512", "64513", "64514"},
+			size:   3,
+		},
+		"size outside range": {
+			pool: data.Pool{Type: PoolPrefix, Range: "10.255.0.0/29", Size: 24},
+			err:  true,
+		},
+		"empty integer range": {
+			pool: data.Pool{Type: PoolInteger, Range: "10-1"},
+			err:  true,
+		},
+		"unknown type": {
+			pool: data.Pool{Type: "other"},
+			err:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := parsePool(tc.pool)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.size, p.size())
+
+			var values []string
+			for i := 0; i < p.size(); i++ {
+				values = append(values, p.value(i))
+			}
+			require.Equal(t, tc.values, values)
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	dir := t.TempDir()
+	d := data.Data{Pools: []data.Pool{
+		{Name: "vlan", Type: PoolInteger, Range: "100-102"},
+	}}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, defaultAllocationsFile), []byte("vlan:\n  users: \"100\"\n"), 0600))
+
+	a, err := loadAllocator(dir, d, true)
+	require.NoError(t, err)
+
+	v, err := a.allocate("vlan", "users")
+	require.NoError(t, err)
+	require.Equal(t, "100", v)
+
+	v, err = a.allocate("vlan", "lab")
+	require.NoError(t, err)
+	require.Equal(t, "101", v)
+
+	v, err = a.allocate("vlan", "mgmt")
+	require.NoError(t, err)
+	require.Equal(t, "102", v)
+
+	_, err = a.allocate("vlan", "guest")
+	require.EqualError(t, err, `pool "vlan" is exhausted`)
+
+	_, err = a.allocate("asn", "core")
+	require.EqualError(t, err, `unknown pool "asn"`)
+
+	// The allocations are stable once recorded.
+	a, err = loadAllocator(dir, d, true)
+	require.NoError(t, err)
+
+	v, err = a.allocate("vlan", "lab")
+	require.NoError(t, err)
+	require.Equal(t, "101", v)
+
+	buf, err := os.ReadFile(filepath.Join(dir, defaultAllocationsFile))
+	require.NoError(t, err)
+	require.Equal(t, "vlan:\n  lab: \"101\"\n  mgmt: \"102\"\n  users: \"100\"\n", string(buf))
+}
+
+func TestAllocateConcurrentRuns(t *testing.T) {
+	dir := t.TempDir()
+	d := data.Data{Pools: []data.Pool{
+		{Name: "vlan", Type: PoolInteger, Range: "100-102"},
+	}}
+
+	// Both runs load the allocations before either allocates.
+	a, err := loadAllocator(dir, d, true)
+	require.NoError(t, err)
+
+	b, err := loadAllocator(dir, d, true)
+	require.NoError(t, err)
+
+	v, err := a.allocate("vlan", "users")
+	require.NoError(t, err)
+	require.Equal(t, "100", v)
+
+	v, err = b.allocate("vlan", "lab")
+	require.NoError(t, err)
+	require.Equal(t, "101", v)
+
+	buf, err := os.ReadFile(filepath.Join(dir, defaultAllocationsFile))
+	require.NoError(t, err)
+	require.Equal(t, "vlan:\n  lab: \"101\"\n  users: \"100\"\n", string(buf))
+}
+
+func TestAllocateNotPersisted(t *testing.T) {
+	dir := t.TempDir()
+	d := data.Data{Pools: []data.Pool{
+		{Name: "vlan", Type: PoolInteger, Range: "100-102"},
+	}}
+
+	a, err := loadAllocator(dir, d, false)
+	require.NoError(t, err)
+
+	v, err := a.allocate("vlan", "users")
+	require.NoError(t, err)
+	require.Equal(t, "100", v)
+
+	_, err = os.Stat(filepath.Join(dir, defaultAllocationsFile))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestPrefixAddress(t *testing.T) {
+	addr, err := prefixAddress("10.255.0.2/31", 1)
+	require.NoError(t, err)
+	require.Equal(t, "10.255.0.3/31", addr)
+
+	addr, err = prefixAddress("2001:db8::/127", 1)
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8::1/127", addr)
+
+	_, err = prefixAddress("10.255.0.2/31", 2)
+	require.Error(t, err)
+}
+
+func TestRenderAllocate(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+template_dir: templates
+template_paths:
+  - "platform/{{ .NetworkHost.Platform }}"
+pools:
+  - name: loopback
+    type: address
+    range: 10.0.0.0/24
+  - name: p2p
+    type: prefix
+    range: 10.255.0.0/24
+    size: 31
+`,
+		"templates/platform/junos/interfaces.tmpl": `
+interfaces {
+    lo0 {
+        unit 0 {
+            family inet {
+                address {{ allocate "loopback" .NetworkHost.Name }}/32;
+            }
+        }
+    }
+    ge-0/0/0 {
+        unit 0 {
+            family inet {
+{{- $link := allocate "p2p" "router1-router2" }}
+{{- if eq .NetworkHost.Name "router1" }}
+                address {{ prefixAddress $link 0 }};
+{{- else }}
+                address {{ prefixAddress $link 1 }};
+{{- end }}
+            }
+        }
+    }
+}
+`,
+	})
+
+	// The hosts are given in reverse, and the second is rendered first, but
+	// the new keys are allocated in order.
+	hosts := []inventory.NetworkHost{
+		{Name: "router2", Domain: "example.com", Platform: "junos"},
+		{Name: "router1", Domain: "example.com", Platform: "junos"},
+	}
+
+	n, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}}, hosts, log.NewNopLogger())
+	require.NoError(t, err)
+
+	router2, err := n.RenderHost(n.Hosts[0])
+	require.NoError(t, err)
+	require.Contains(t, router2, "address 10.0.0.1/32;")
+	require.Contains(t, router2, "address 10.255.0.1/31;")
+
+	router1, err := n.RenderHost(n.Hosts[1])
+	require.NoError(t, err)
+	require.Contains(t, router1, "address 10.0.0.0/32;")
+	require.Contains(t, router1, "address 10.255.0.0/31;")
+
+	// Rendering without committing leaves the data directory untouched.
+	_, err = os.Stat(filepath.Join(dir, defaultAllocationsFile))
+	require.True(t, os.IsNotExist(err))
+
+	n, err = NewWithHosts(Config{Data: DataConfig{Directory: dir}, Commit: true}, hosts, log.NewNopLogger())
+	require.NoError(t, err)
+
+	router1, err = n.RenderHost(n.Hosts[1])
+	require.NoError(t, err)
+	require.Contains(t, router1, "address 10.0.0.0/32;")
+
+	buf, err := os.ReadFile(filepath.Join(dir, defaultAllocationsFile))
+	require.NoError(t, err)
+	require.Equal(t, "loopback:\n  router1: 10.0.0.0\n  router2: 10.0.0.1\np2p:\n  router1-router2: 10.255.0.0/31\n", string(buf))
+}
@@ -0,0 +1,55 @@
+package netconfig
+
+import "sync/atomic"
+
+// Decision is the decision of an operator about the candidate configuration
+// of a host.
+type Decision int
+
+// The decisions which an Approver may return.
+const (
+	DecisionSkip Decision = iota
+	DecisionCommit
+	DecisionCommitConfirmed
+	DecisionAbort
+)
+
+// defaultConfirmMinutes is the confirm timeout used when an operator chooses
+// a commit confirmed and no commit-confirmed timeout is configured.
+const defaultConfirmMinutes = 10
+
+// Approver decides whether the candidate configuration of a host should be
+// committed, given the diff against the active configuration.  Calls to
+// Approve are serialized, so an implementation may prompt an operator.
+type Approver interface {
+	Approve(host Host, diff string) (Decision, error)
+}
+
+// SetApprover installs an Approver which is consulted for every host with a
+// non-empty diff, in place of the commit options.
+func (n *NetConfig) SetApprover(a Approver) {
+	n.approver = a
+}
+
+// approve asks the Approver about the diff of a host.  Once any host has been
+// answered with DecisionAbort, all remaining hosts are skipped without being
+// presented.
+func (n *NetConfig) approve(host Host, diff string) (Decision, error) {
+	n.approveMtx.Lock()
+	defer n.approveMtx.Unlock()
+
+	if atomic.LoadInt32(&n.aborted) == 1 {
+		return DecisionSkip, nil
+	}
+
+	decision, err := n.approver.Approve(host, diff)
+	if err != nil {
+		return DecisionSkip, err
+	}
+
+	if decision == DecisionAbort {
+		atomic.StoreInt32(&n.aborted, 1)
+	}
+
+	return decision, nil
+}
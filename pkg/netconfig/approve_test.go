@@ -0,0 +1,49 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingApprover struct {
+	decisions []Decision
+	presented []string
+}
+
+func (r *recordingApprover) Approve(host Host, diff string) (Decision, error) {
+	r.presented = append(r.presented, host.HostName)
+
+	d := r.decisions[0]
+	r.decisions = r.decisions[1:]
+
+	return d, nil
+}
+
+func TestApproveAbort(t *testing.T) {
+	approver := &recordingApprover{
+		decisions: []Decision{DecisionCommit, DecisionAbort, DecisionCommit},
+	}
+
+	n := &NetConfig{}
+	n.SetApprover(approver)
+
+	expected := []Decision{DecisionCommit, DecisionAbort, DecisionSkip, DecisionSkip}
+	for i, name := range []string{"a", "b", "c", "d"} {
+		d, err := n.approve(Host{HostName: name}, "+ set foo")
+		require.NoError(t, err)
+		require.Equal(t, expected[i], d, name)
+	}
+
+	require.Equal(t, []string{"a", "b"}, approver.presented)
+}
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "[edit system]\n-  host-name a;\n+  host-name b;\n   domain-name example.com;"
+	expected := colorCyan + "[edit system]" + colorReset + "\n" +
+		colorRed + "-  host-name a;" + colorReset + "\n" +
+		colorGreen + "+  host-name b;" + colorReset + "\n" +
+		"   domain-name example.com;"
+
+	require.Equal(t, expected, ColorizeDiff(diff))
+}
@@ -0,0 +1,210 @@
+package netconfig
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// ValidateNetwork renders the configuration of every host and checks it
+// against the assertions of the data directory, and checks the consistency of
+// the data of the hosts with each other.
+func (n *NetConfig) ValidateNetwork() error {
+	err := n.forEachHost("validate", func(h Host) error {
+		rendered, err := n.RenderHost(h)
+		if err != nil {
+			return err
+		}
+
+		return n.assertHost(h, rendered)
+	})
+
+	consistencyErr := n.checkConsistency()
+	if consistencyErr == nil {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s; %s", err, consistencyErr)
+	}
+
+	return consistencyErr
+}
+
+// checkConsistency returns an error describing every consistency check which
+// the data of the hosts fails.
+func (n *NetConfig) checkConsistency() error {
+	failures := n.CheckConsistency()
+	if len(failures) == 0 {
+		return nil
+	}
+
+	for _, f := range failures {
+		_ = level.Error(n.logger).Log("msg", "consistency check failed", "failure", f)
+	}
+
+	return fmt.Errorf("%d consistency check(s) failed: %s", len(failures), strings.Join(failures, "; "))
+}
+
+// assertHost returns an error describing every assertion which the rendered
+// configuration of the host fails.
+func (n *NetConfig) assertHost(host Host, rendered string) error {
+	failures, err := n.checkAssertions(host, rendered)
+	if err != nil {
+		return err
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	for _, f := range failures {
+		_ = level.Error(n.logger).Log("msg", "assertion failed", "host", host.HostName, "failure", f)
+	}
+
+	return fmt.Errorf("%d assertion(s) failed on %s: %s", len(failures), host.HostName, strings.Join(failures, "; "))
+}
+
+// checkAssertions returns a description of each failure of the assertions
+// which apply to the host.
+func (n *NetConfig) checkAssertions(host Host, rendered string) ([]string, error) {
+	var applicable []data.Assertion
+	for _, a := range n.Data.Assertions {
+		if assertionApplies(a, host) {
+			applicable = append(applicable, a)
+		}
+	}
+
+	if len(applicable) == 0 {
+		return nil, nil
+	}
+
+	statements, err := parseStatements(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered configuration of %s: %w", host.HostName, err)
+	}
+
+	var failures []string
+	for i, a := range applicable {
+		name := a.Name
+		if name == "" {
+			name = fmt.Sprintf("assertion %d", i+1)
+		}
+
+		for _, f := range checkAssertion(a, statements) {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, f))
+		}
+	}
+
+	return failures, nil
+}
+
+// assertionApplies returns true when the roles and hosts of the assertion
+// match the host.
+func assertionApplies(a data.Assertion, host Host) bool {
+	return hostMatches(a.Roles, a.Hosts, host)
+}
+
+// hostMatches returns true when the host has one of the roles and its short or
+// fully qualified name matches one of the host patterns.  Empty roles or hosts
+// match every host.
+func hostMatches(roles, hosts []string, host Host) bool {
+	if len(roles) > 0 {
+		var found bool
+		for _, r := range roles {
+			if r == host.NetworkHost.Role {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(hosts) == 0 {
+		return true
+	}
+
+	for _, pattern := range hosts {
+		for _, name := range []string{host.NetworkHost.Name, host.HostName} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkAssertion returns the failures of a single assertion.
+func checkAssertion(a data.Assertion, statements []statement) []string {
+	if a.Each == "" {
+		return checkStanza("configuration", a, statements)
+	}
+
+	each := strings.Fields(a.Each)
+
+	var (
+		order   []string
+		stanzas = map[string][]statement{}
+	)
+
+	for _, s := range statements {
+		if !s.hasPrefix(each) {
+			continue
+		}
+
+		key := s[:len(each)].String()
+		if _, ok := stanzas[key]; !ok {
+			order = append(order, key)
+		}
+		stanzas[key] = append(stanzas[key], s[len(each):])
+	}
+
+	var failures []string
+	for _, key := range order {
+		failures = append(failures, checkStanza(key, a, stanzas[key])...)
+	}
+
+	return failures
+}
+
+// checkStanza checks the Contains and NotContains of the assertion against
+// the statements of a single stanza.
+func checkStanza(stanza string, a data.Assertion, statements []statement) []string {
+	var failures []string
+
+	for _, c := range a.Contains {
+		if !containsHierarchy(statements, c) {
+			failures = append(failures, fmt.Sprintf("%s does not contain %q", stanza, c))
+		}
+	}
+
+	for _, c := range a.NotContains {
+		if containsHierarchy(statements, c) {
+			failures = append(failures, fmt.Sprintf("%s contains %q", stanza, c))
+		}
+	}
+
+	return failures
+}
+
+// containsHierarchy returns true when a statement is at or below the
+// hierarchy.
+func containsHierarchy(statements []statement, hierarchy string) bool {
+	patterns := strings.Fields(hierarchy)
+
+	for _, s := range statements {
+		if s.hasPrefix(patterns) {
+			return true
+		}
+	}
+
+	return false
+}
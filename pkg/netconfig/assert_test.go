@@ -0,0 +1,105 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+const testAssertConfig = `
+system {
+    host-name router1;
+    services {
+        ssh;
+        telnet;
+    }
+}
+interfaces {
+    replace:
+    ge-0/0/0 {
+        description "uplink";
+        unit 0 {
+            family inet {
+                address 192.0.2.1/31;
+            }
+        }
+    }
+    ge-0/0/1 {
+        unit 0;
+    }
+    lo0 {
+        unit 0;
+    }
+}
+`
+
+func TestCheckAssertions(t *testing.T) {
+	host := Host{
+		HostName:    "router1.example.com",
+		NetworkHost: &inventory.NetworkHost{Name: "router1", Domain: "example.com", Role: "core"},
+	}
+
+	cases := map[string]struct {
+		assertion data.Assertion
+		failures  []string
+	}{
+		"contains": {
+			assertion: data.Assertion{Name: "ssh", Contains: []string{"system services ssh"}},
+		},
+		"not contains": {
+			assertion: data.Assertion{Name: "no telnet", NotContains: []string{"system services telnet"}},
+			failures:  []string{`no telnet: configuration contains "system services telnet"`},
+		},
+		"glob": {
+			assertion: data.Assertion{Contains: []string{"interfaces ge-* unit 0 family inet"}},
+		},
+		"each": {
+			assertion: data.Assertion{Name: "descriptions", Each: "interfaces ge-*", Contains: []string{"description"}},
+			failures:  []string{`descriptions: interfaces ge-0/0/1 does not contain "description"`},
+		},
+		"other role": {
+			assertion: data.Assertion{Roles: []string{"edge"}, NotContains: []string{"system"}},
+		},
+		"other host": {
+			assertion: data.Assertion{Hosts: []string{"router2*"}, NotContains: []string{"system"}},
+		},
+		"matching host": {
+			assertion: data.Assertion{Roles: []string{"core"}, Hosts: []string{"router1"}, Contains: []string{"snmp"}},
+			failures:  []string{`assertion 1: configuration does not contain "snmp"`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := &NetConfig{Data: data.Data{Assertions: []data.Assertion{tc.assertion}}}
+
+			failures, err := n.checkAssertions(host, testAssertConfig)
+			require.NoError(t, err)
+			require.Equal(t, tc.failures, failures)
+		})
+	}
+}
+
+func TestConfigureNetworkAssertionFailure(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data.yaml"] += `
+assertions:
+  - name: ntp
+    contains:
+      - "system ntp server 10.0.0.1"
+`
+
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Data: DataConfig{Directory: writeTestData(t, files)}}, device)
+
+	require.Error(t, n.ValidateNetwork())
+	require.Error(t, n.ConfigureNetwork())
+	require.Empty(t, device.RPCs())
+	require.Empty(t, device.Commits())
+}
@@ -0,0 +1,161 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// backupTimeFormat is the format of the timestamp used to name backup files.
+const backupTimeFormat = "20060102T150405Z"
+
+const backupFileSuffix = ".conf"
+
+// BackupNetwork captures the active configuration of all discovered network
+// devices.
+func (n *NetConfig) BackupNetwork() error {
+	if n == nil {
+		return fmt.Errorf("unable to backup network with nil NetConfig")
+	}
+
+	return n.forEachHost("backup", func(h Host) error {
+		path, err := n.BackupHost(h)
+		if err != nil {
+			return err
+		}
+
+		_ = level.Info(n.logger).Log("msg", "backup complete", "host", h.HostName, "path", path)
+
+		return nil
+	})
+}
+
+// BackupHost captures the active configuration of a host and writes it to the
+// backup directory, returning the path of the new backup file.
+func (n *NetConfig) BackupHost(host Host) (string, error) {
+	session, err := n.dial(host)
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	config, err := getConfigText(session)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get configuration from "+host.HostName)
+	}
+
+	dir := filepath.Join(n.cfg.Backup.Directory, host.HostName)
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, time.Now().UTC().Format(backupTimeFormat)+backupFileSuffix)
+	err = os.WriteFile(path, []byte(config), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// RestoreHost loads a previously captured backup onto the host, replacing the
+// entire candidate configuration.  When timestamp is empty, the most recent
+// backup is used, otherwise the most recent backup whose timestamp begins with
+// the given value.  The difference is shown and committed according to the
+// commit options.
+func (n *NetConfig) RestoreHost(host Host, timestamp string) error {
+	path, err := n.findBackup(host, timestamp)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read backup "+path)
+	}
+
+	_ = level.Info(n.logger).Log("msg", "restoring backup", "host", host.HostName, "path", path)
+
+	ctx := context.Background()
+
+	session, closeSession, err := n.openSession(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	unlockSession, err := n.prepareCandidate(ctx, session, host, closeSession)
+	if err != nil {
+		return err
+	}
+	defer unlockSession()
+
+	err = loadConfigText(session, "override", string(b))
+	if err != nil {
+		return fmt.Errorf("unable to load backup on %s: %s", host.HostName, err)
+	}
+
+	err = n.applyCandidate(ctx, session, host)
+	n.forgetHost(host)
+
+	return err
+}
+
+// Backups returns the timestamps of the available backups for a host, oldest
+// first.
+func (n *NetConfig) Backups(host Host) ([]string, error) {
+	pattern := filepath.Join(n.cfg.Backup.Directory, host.HostName, "*"+backupFileSuffix)
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamps []string
+	for _, f := range files {
+		timestamps = append(timestamps, strings.TrimSuffix(filepath.Base(f), backupFileSuffix))
+	}
+
+	sort.Strings(timestamps)
+
+	return timestamps, nil
+}
+
+// findBackup returns the path of the most recent backup for the host whose
+// timestamp has the given prefix.
+func (n *NetConfig) findBackup(host Host, timestamp string) (string, error) {
+	timestamps, err := n.Backups(host)
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(timestamps) - 1; i >= 0; i-- {
+		if strings.HasPrefix(timestamps[i], timestamp) {
+			return filepath.Join(n.cfg.Backup.Directory, host.HostName, timestamps[i]+backupFileSuffix), nil
+		}
+	}
+
+	if timestamp == "" {
+		return "", fmt.Errorf("no backups found for host %s", host.HostName)
+	}
+
+	return "", fmt.Errorf("no backup matching %q found for host %s", timestamp, host.HostName)
+}
+
+// Host returns the discovered host with the given name.  Either the fully
+// qualified name or the short name of the host may be used.
+func (n *NetConfig) Host(name string) (Host, error) {
+	for _, h := range n.Hosts {
+		if h.HostName == name || h.NetworkHost.Name == name {
+			return h, nil
+		}
+	}
+
+	return Host{}, fmt.Errorf("host %s not found", name)
+}
@@ -0,0 +1,61 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+)
+
+func TestFindBackup(t *testing.T) {
+	dir := t.TempDir()
+
+	host := Host{
+		HostName:    "router1.example.com",
+		NetworkHost: &inventory.NetworkHost{Name: "router1", Domain: "example.com"},
+	}
+
+	n := &NetConfig{
+		logger: log.NewNopLogger(),
+		cfg:    &Config{Backup: BackupConfig{Directory: dir}},
+		Hosts:  []Host{host},
+	}
+
+	_, err := n.findBackup(host, "")
+	require.Error(t, err)
+
+	hostDir := filepath.Join(dir, host.HostName)
+	require.NoError(t, os.MkdirAll(hostDir, 0750))
+	for _, ts := range []string{"20220101T000000Z", "20220102T120000Z", "20220102T000000Z", "20220201T000000Z"} {
+		require.NoError(t, os.WriteFile(filepath.Join(hostDir, ts+backupFileSuffix), []byte("system {}\n"), 0600))
+	}
+
+	timestamps, err := n.Backups(host)
+	require.NoError(t, err)
+	require.Equal(t, []string{"20220101T000000Z", "20220102T000000Z", "20220102T120000Z", "20220201T000000Z"}, timestamps)
+
+	cases := map[string]string{
+		"":                 "20220201T000000Z",
+		"20220102":         "20220102T120000Z",
+		"20220101T000000Z": "20220101T000000Z",
+	}
+
+	for timestamp, expected := range cases {
+		path, err := n.findBackup(host, timestamp)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(hostDir, expected+backupFileSuffix), path)
+	}
+
+	_, err = n.findBackup(host, "2021")
+	require.Error(t, err)
+
+	h, err := n.Host("router1")
+	require.NoError(t, err)
+	require.Equal(t, host.HostName, h.HostName)
+
+	_, err = n.Host("router2")
+	require.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// fileCache reads and parses each data file and template of the data directory
+// once, sharing the parsed values across the hosts of a NetConfig.  It is safe
+// for concurrent use.  A nil fileCache reads the files on every call.
+type fileCache struct {
+	mtx     sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a value loaded once, by the first caller.
+type cacheEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func newFileCache() *fileCache {
+	return &fileCache{entries: map[string]*cacheEntry{}}
+}
+
+// load returns the value cached for key, calling fn to load it on first use.
+// Callers waiting for the same key block until it is loaded.
+func (c *fileCache) load(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fn()
+	}
+
+	c.mtx.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	c.mtx.Unlock()
+
+	e.once.Do(func() {
+		e.value, e.err = fn()
+	})
+
+	return e.value, e.err
+}
+
+// hostData returns the data of the hierarchy file at path.  The result is a
+// copy which may be merged into without modifying the cache.
+func (c *fileCache) hostData(path string) (data.HostData, error) {
+	v, err := c.load("data:"+path, func() (interface{}, error) {
+		d := data.HostData{}
+		err := loadHostDataFile(path, &d)
+		return d, err
+	})
+	if err != nil {
+		return data.HostData{}, err
+	}
+
+	return copyHostData(v.(data.HostData)), nil
+}
+
+// template returns the template at path, parsed with the functions.
+func (c *fileCache) template(path string, funcs template.FuncMap) (*template.Template, error) {
+	v, err := c.load("template:"+path, func() (interface{}, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read path "+path)
+		}
+
+		tmpl, err := template.New("test").Funcs(funcs).Parse(string(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse template "+path)
+		}
+
+		return tmpl, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*template.Template), nil
+}
+
+// exists returns true when a file exists at path.
+func (c *fileCache) exists(path string) bool {
+	v, _ := c.load("stat:"+path, func() (interface{}, error) {
+		_, err := os.Stat(path)
+		return err == nil, nil
+	})
+
+	return v.(bool)
+}
+
+// glob returns the files matching the pattern.
+func (c *fileCache) glob(pattern string) ([]string, error) {
+	v, err := c.load("glob:"+pattern, func() (interface{}, error) {
+		return filepath.Glob(pattern)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]string), nil
+}
+
+// copyHostData returns a copy of d whose maps are not shared with d, as
+// merging writes into the maps of the destination.  Slices are replaced
+// rather than written into when merging, and are shared.
+func copyHostData(d data.HostData) data.HostData {
+	if d.PolicyOptions.Statements != nil {
+		statements := make(map[string]data.PolicyStatement, len(d.PolicyOptions.Statements))
+		for k, v := range d.PolicyOptions.Statements {
+			statements[k] = v
+		}
+		d.PolicyOptions.Statements = statements
+	}
+
+	return d
+}
@@ -0,0 +1,149 @@
+package netconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func TestFileCacheHostData(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"global.yaml": `
+ntp_servers:
+  - 10.0.0.1
+policy_options:
+  statements:
+    global:
+      name: global
+`,
+	})
+	path := filepath.Join(dir, "global.yaml")
+
+	c := newFileCache()
+
+	d, err := c.hostData(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1"}, d.NTPServers)
+
+	// The file is not read again.
+	require.NoError(t, os.Remove(path))
+
+	d.PolicyOptions.Statements["host"] = data.PolicyStatement{Name: "host"}
+
+	again, err := c.hostData(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1"}, again.NTPServers)
+	require.NotContains(t, again.PolicyOptions.Statements, "host")
+}
+
+func TestFileCacheTemplateError(t *testing.T) {
+	c := newFileCache()
+
+	_, err := c.template(filepath.Join(t.TempDir(), "missing.tmpl"), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read path")
+}
+
+// The benchmark data has a hierarchy of global, role and host files, with a
+// host file for every tenth host, and several templates per platform.
+const benchmarkHosts = 2000
+
+func benchmarkData(b *testing.B) (string, []inventory.NetworkHost) {
+	files := map[string]string{
+		"data.yaml": `
+template_dir: templates
+template_paths:
+  - "platform/{{ .NetworkHost.Platform }}"
+  - "role/{{ .NetworkHost.Role }}"
+hierarchy:
+  - "global.yaml"
+  - "role/{{ .NetworkHost.Role }}.yaml"
+  - "host/{{ .NetworkHost.Name }}.yaml"
+`,
+		"data/global.yaml": `
+ntp_servers:
+  - 10.0.0.1
+  - 10.0.0.2
+lldp_interfaces:
+  - all
+`,
+		"templates/platform/junos/system.tmpl": `
+system {
+    host-name {{ .NetworkHost.Name }};
+    ntp {
+{{- range .Data.NTPServers }}
+        server {{ . }};
+{{- end }}
+    }
+}
+`,
+		"templates/platform/junos/lldp.tmpl": `
+protocols {
+    lldp {
+{{- range .Data.LLDPInterfaces }}
+        interface {{ . }};
+{{- end }}
+    }
+}
+`,
+	}
+
+	var hosts []inventory.NetworkHost
+
+	for r := 0; r < 4; r++ {
+		role := fmt.Sprintf("role%d", r)
+		files["data/role/"+role+".yaml"] = "dhcp_server: 10.0.1.1\n"
+		files["templates/role/"+role+"/dhcp.tmpl"] = "forwarding-options {\n    dhcp-relay {\n        server-group {{ .Data.DHCPServer }};\n    }\n}\n"
+	}
+
+	for i := 0; i < benchmarkHosts; i++ {
+		name := fmt.Sprintf("host%04d", i)
+		if i%10 == 0 {
+			files["data/host/"+name+".yaml"] = "ntp_servers:\n  - 10.0.0.3\n"
+		}
+
+		hosts = append(hosts, inventory.NetworkHost{
+			Name:     name,
+			Domain:   "example.com",
+			Platform: "junos",
+			Role:     fmt.Sprintf("role%d", i%4),
+		})
+	}
+
+	return writeTestData(b, files), hosts
+}
+
+func benchmarkLoadAndRender(b *testing.B, newCache func() *fileCache) {
+	dir, hosts := benchmarkData(b)
+	cfg := Config{Data: DataConfig{Directory: dir}}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n, err := newWithHosts(cfg, hosts, newCache(), log.NewNopLogger())
+		require.NoError(b, err)
+		require.Len(b, n.Hosts, benchmarkHosts)
+
+		_, errs := n.renderHosts(n.Hosts)
+		for _, err := range errs {
+			require.NoError(b, err)
+		}
+	}
+}
+
+func BenchmarkLoadAndRender(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		benchmarkLoadAndRender(b, newFileCache)
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		benchmarkLoadAndRender(b, func() *fileCache { return nil })
+	})
+}
@@ -2,6 +2,11 @@ package netconfig
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/xaque208/znet/modules/inventory"
 )
@@ -11,9 +16,30 @@ type Config struct {
 	OtelEndpoint    string           `yaml:"otel_endpoint"`
 	Data            DataConfig       `yaml:"data"`
 	Inventory       inventory.Config `yaml:"inventory"`
+	Backup          BackupConfig     `yaml:"backup"`
+	Guardrails      GuardrailConfig  `yaml:"guardrails"`
+	Lockout         string           `yaml:"lockout,omitempty"`
+	Metrics         MetricsConfig    `yaml:"metrics"`
+	Serve           ServeConfig      `yaml:"serve"`
+	Reconcile       ReconcileConfig  `yaml:"reconcile"`
+	Verify          VerifyConfig     `yaml:"verify"`
+	Preflight       PreflightConfig  `yaml:"preflight"`
+	StateFile       string           `yaml:"state_file,omitempty"`
+	Operator        string           `yaml:"operator,omitempty"`
 	Commit          bool
 	Diff            bool
+	Interactive     bool
 	CommitConfirmed int
+	CommitAt        string
+	Limit           string
+	Update          bool
+	DryRun          bool
+	Force           bool
+	CheckDrift      bool
+	Private         bool
+	JSON            bool
+
+	OverrideGuardrails bool
 }
 
 // JunosConfig is the configuration for Junos devices.
@@ -23,6 +49,84 @@ type JunosConfig struct {
 	Keyfile  string   `yaml:"keyfile,omitempty"`
 }
 
+// BackupConfig is the configuration for device configuration backups.
+type BackupConfig struct {
+	Directory string `yaml:"directory,omitempty"`
+}
+
+// GuardrailConfig limits the size of the changes committed to each host and
+// during a run, and the hierarchies which may have statements removed.  A zero
+// maximum is unlimited.
+type GuardrailConfig struct {
+	MaxAdded      int      `yaml:"max_added,omitempty"`
+	MaxRemoved    int      `yaml:"max_removed,omitempty"`
+	MaxRunAdded   int      `yaml:"max_run_added,omitempty"`
+	MaxRunRemoved int      `yaml:"max_run_removed,omitempty"`
+	Protected     []string `yaml:"protected,omitempty"`
+}
+
+// defaultProtected are the hierarchies protected when none are configured,
+// covering the access netconfig needs to reach the device.
+var defaultProtected = []string{
+	"system login",
+	"system services ssh",
+	"interfaces fxp0",
+	"interfaces em0",
+	"interfaces me0",
+}
+
+// MetricsConfig is the configuration for exporting metrics.  The listen
+// address serves the metrics for the lifetime of the process, while the
+// textfile and pushgateway receive the metrics at the end of a one-shot run.
+type MetricsConfig struct {
+	ListenAddress string `yaml:"listen_address,omitempty"`
+	Textfile      string `yaml:"textfile,omitempty"`
+	Pushgateway   string `yaml:"pushgateway,omitempty"`
+	Job           string `yaml:"job,omitempty"`
+}
+
+// ServeConfig is the configuration of the serve command.  Tokens maps the name
+// of each client, recorded as the operator of its pushes, to the bearer token
+// it must present to make calls.  The listeners are served with TLS from the
+// certificate and key files, which are required unless Insecure is set, when
+// the service is served in the clear and only the calls which touch devices,
+// ie diffs and pushes, require a token.
+type ServeConfig struct {
+	GRPCListenAddress string            `yaml:"grpc_listen_address,omitempty"`
+	HTTPListenAddress string            `yaml:"http_listen_address,omitempty"`
+	Tokens            map[string]string `yaml:"tokens,omitempty"`
+	TLSCertFile       string            `yaml:"tls_cert_file,omitempty"`
+	TLSKeyFile        string            `yaml:"tls_key_file,omitempty"`
+	Insecure          bool              `yaml:"insecure,omitempty"`
+}
+
+// ReconcileConfig is the configuration of the reconcile command, which checks
+// the data directory for a new revision every interval.  A zero interval
+// reconciles once and exits.
+type ReconcileConfig struct {
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// VerifyConfig is the configuration of the operational checks made after a
+// commit.  The checks are captured again every interval until they no longer
+// regress, or the timeout passes.
+type VerifyConfig struct {
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// PreflightConfig is the configuration of the checks of the health of each
+// host made before it is locked.  The versions are compared up to the
+// precision given, so that "20.4" allows any 20.4 release, and an empty
+// version is not checked.  MinFreeDisk is the percentage of free space
+// required on the filesystems of the device.
+type PreflightConfig struct {
+	Enabled     bool   `yaml:"enabled,omitempty"`
+	MinVersion  string `yaml:"min_version,omitempty"`
+	MaxVersion  string `yaml:"max_version,omitempty"`
+	MinFreeDisk int    `yaml:"min_free_disk,omitempty"`
+}
+
 // DataConfig is the configuration for data.
 type DataConfig struct {
 	Directory string `yaml:"directory,omitempty"`
@@ -30,9 +134,84 @@ type DataConfig struct {
 
 func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	c.CommitConfirmed = 0
+	c.Guardrails.Protected = defaultProtected
 	f.StringVar(&c.Junos.Username, "junos.username", "", "")
 	f.StringVar(&c.Junos.Keyfile, "junos.keyfile", "", "")
 	f.StringVar(&c.OtelEndpoint, "otel_endpoint", "", "otel endpoint, eg: tempo:4317")
 	f.BoolVar(&c.Commit, "commit", false, "commit the diff")
 	f.BoolVar(&c.Diff, "diff", true, "show the diff")
+	f.BoolVar(&c.Interactive, "interactive", false, "prompt for approval of the diff of each host before committing")
+	f.IntVar(&c.CommitConfirmed, "commit-confirmed", 0, "minutes to wait for confirmation before the device rolls back a commit")
+	f.StringVar(&c.CommitAt, "commit-at", "", "schedule the commit for a time, eg: \"02:00\" or \"2022-01-10 02:00\", or \"window\" to use the maintenance window of each host")
+	f.BoolVar(&c.Update, "update", false, "regenerate the golden files when running the test command")
+	f.StringVar(&c.Limit, "limit", "", "comma separated list of host names or glob patterns to limit the run to, eg: \"core*,edge1\"")
+	f.StringVar(&c.Operator, "operator", os.Getenv("USER"), "name of the operator recorded in commit comments")
+	f.IntVar(&c.Guardrails.MaxAdded, "guardrails.max-added", 0, "maximum lines added to the configuration of a host, 0 for unlimited")
+	f.IntVar(&c.Guardrails.MaxRemoved, "guardrails.max-removed", 0, "maximum lines removed from the configuration of a host, 0 for unlimited")
+	f.IntVar(&c.Guardrails.MaxRunAdded, "guardrails.max-run-added", 0, "maximum lines added across all hosts during a run, 0 for unlimited")
+	f.IntVar(&c.Guardrails.MaxRunRemoved, "guardrails.max-run-removed", 0, "maximum lines removed across all hosts during a run, 0 for unlimited")
+	f.StringVar(&c.Lockout, "lockout", LockoutConfirm, "handling of changes affecting management access to a device, one of \"confirm\", \"refuse\" or \"off\"")
+	f.BoolVar(&c.OverrideGuardrails, "override-guardrails", false, "commit changes which exceed the guardrails")
+	f.StringVar(&c.Metrics.ListenAddress, "metrics.listen-address", "", "address on which to serve metrics, eg: \":9100\"")
+	f.StringVar(&c.Metrics.Textfile, "metrics.textfile", "", "file to which metrics are written at the end of the run, in the node_exporter textfile format")
+	f.StringVar(&c.Metrics.Pushgateway, "metrics.pushgateway", "", "url of a Pushgateway to which metrics are pushed at the end of the run")
+	f.StringVar(&c.Metrics.Job, "metrics.job", "netconfig", "job name used when pushing metrics")
+	f.StringVar(&c.Serve.GRPCListenAddress, "serve.grpc-listen-address", ":9090", "address on which the serve command listens for gRPC")
+	f.StringVar(&c.Serve.HTTPListenAddress, "serve.http-listen-address", ":8080", "address on which the serve command listens for the JSON gateway")
+	f.StringVar(&c.Serve.TLSCertFile, "serve.tls-cert-file", "", "certificate file with which the serve command serves TLS")
+	f.StringVar(&c.Serve.TLSKeyFile, "serve.tls-key-file", "", "key file of the certificate with which the serve command serves TLS")
+	f.BoolVar(&c.Serve.Insecure, "serve.insecure", false, "serve without TLS, and without authenticating the calls which do not touch devices")
+	f.DurationVar(&c.Reconcile.Interval, "reconcile.interval", time.Minute, "interval at which the reconcile command checks the data directory for changes, 0 to reconcile once")
+	f.DurationVar(&c.Verify.Timeout, "verify.timeout", 2*time.Minute, "time to wait after a commit for the operational checks of a host to recover")
+	f.DurationVar(&c.Verify.Interval, "verify.interval", 10*time.Second, "interval at which the operational checks are captured again while they regress")
+	f.BoolVar(&c.Private, "private", false, "load the configuration into a private candidate, rather than locking the shared candidate")
+	f.BoolVar(&c.Preflight.Enabled, "preflight", true, "check the health of each host before locking it, skipping unhealthy hosts")
+	f.StringVar(&c.Preflight.MinVersion, "preflight.min-version", "", "oldest Junos version which may be configured, eg: \"20.4R3\"")
+	f.StringVar(&c.Preflight.MaxVersion, "preflight.max-version", "", "newest Junos version which may be configured, eg: \"22.4\"")
+	f.IntVar(&c.Preflight.MinFreeDisk, "preflight.min-free-disk", 10, "percentage of disk space which must be free on each host")
+	f.StringVar(&c.StateFile, "state-file", "", "file recording the configuration last applied to each host, enabling incremental runs, eg: \"netconfig-state.json\"")
+	f.BoolVar(&c.Force, "force", false, "push every host, including those whose rendered configuration is unchanged since it was last applied")
+	f.BoolVar(&c.CheckDrift, "check-drift", false, "diff the hosts whose rendered configuration is unchanged, reporting changes made to them outside of netconfig")
+	f.BoolVar(&c.JSON, "json", false, "print the results of the verify-topology command as JSON")
+	f.BoolVar(&c.DryRun, "dry-run", false, "report the hosts which would be pushed without pushing them")
+	f.StringVar(&c.Backup.Directory, "backup.directory", "backups", "directory in which device configuration backups are stored")
+}
+
+// Validate returns an error for a configuration whose values are not among
+// those accepted, so that a mistyped option does not silently fall back to
+// another behavior.
+func (c *Config) Validate() error {
+	switch c.Lockout {
+	case "", LockoutConfirm, LockoutRefuse, LockoutOff:
+	default:
+		return fmt.Errorf("invalid lockout %q, must be one of %q, %q or %q", c.Lockout, LockoutConfirm, LockoutRefuse, LockoutOff)
+	}
+
+	return nil
+}
+
+// selected returns true when the host is matched by the limit, or when no
+// limit is configured.  The patterns of the limit are matched against both the
+// short and fully qualified names of the host.
+func (c *Config) selected(host *inventory.NetworkHost) bool {
+	if strings.TrimSpace(c.Limit) == "" {
+		return true
+	}
+
+	fqdn := strings.Join([]string{host.Name, host.Domain}, ".")
+
+	for _, pattern := range strings.Split(c.Limit, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		for _, name := range []string{host.Name, fqdn} {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,42 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+)
+
+func TestConfigSelected(t *testing.T) {
+	host := &inventory.NetworkHost{Name: "core1", Domain: "example.com"}
+
+	cases := map[string]bool{
+		"":                    true,
+		"core1":               true,
+		"core1.example.com":   true,
+		"core*":               true,
+		"edge1, core*":        true,
+		"*.example.com":       true,
+		"edge1":               false,
+		"core1.example.net":   false,
+		"core2,edge*":         false,
+		"core1.example.com.x": false,
+	}
+
+	for limit, expected := range cases {
+		cfg := Config{Limit: limit}
+		require.Equal(t, expected, cfg.selected(host), limit)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, lockout := range []string{"", LockoutConfirm, LockoutRefuse, LockoutOff} {
+		c := Config{Lockout: lockout}
+		require.NoError(t, c.Validate(), lockout)
+	}
+
+	for _, lockout := range []string{"refuse ", "deny", "Off"} {
+		c := Config{Lockout: lockout}
+		require.Error(t, c.Validate(), lockout)
+	}
+}
@@ -0,0 +1,347 @@
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// interfaceAddress is an address configured on an interface of a host.  A
+// shared address is intentionally configured on more than one host, ie a VRRP
+// virtual address or an anycast address.
+type interfaceAddress struct {
+	host   string
+	iface  string
+	ip     net.IP
+	shared bool
+}
+
+func (a interfaceAddress) String() string {
+	return fmt.Sprintf("%s %s", a.host, a.iface)
+}
+
+// CheckConsistency checks the data of the hosts against each other, returning
+// a description of each inconsistency: addresses configured on more than one
+// host, BGP neighbors which do not peer back or whose ASN does not match, VLANs
+// trunked on only one end of a link, and router IDs used by more than one
+// host.  VRRP virtual addresses and the anycast addresses of the data may be
+// shared by hosts.  Neighbors and links of hosts which are not selected are
+// not checked.
+func (n *NetConfig) CheckConsistency() []string {
+	hosts := make([]Host, len(n.Hosts))
+	copy(hosts, n.Hosts)
+
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].HostName < hosts[j].HostName
+	})
+
+	var failures []string
+
+	anycast, invalid := parsePrefixes(n.Data.Anycast)
+	failures = append(failures, invalid...)
+
+	addrs := map[string][]interfaceAddress{}
+	for _, h := range hosts {
+		for _, a := range hostAddresses(h) {
+			a.shared = a.shared || containsIP(anycast, a.ip)
+			addrs[h.HostName] = append(addrs[h.HostName], a)
+		}
+	}
+
+	failures = append(failures, checkDuplicateAddresses(hosts, addrs)...)
+	failures = append(failures, checkBGPNeighbors(hosts, addrs)...)
+	failures = append(failures, checkLinkVLANs(hosts, n.Data.Links)...)
+	failures = append(failures, checkRouterIDs(hosts)...)
+
+	return failures
+}
+
+// parsePrefixes parses the anycast addresses and prefixes, an address being a
+// prefix of its own, returning a failure for each which is invalid.
+func parsePrefixes(prefixes []string) ([]*net.IPNet, []string) {
+	var (
+		nets     []*net.IPNet
+		failures []string
+	)
+
+	for _, p := range prefixes {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				failures = append(failures, fmt.Sprintf("invalid anycast address %q", p))
+				continue
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(p)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid anycast prefix %q", p))
+			continue
+		}
+		nets = append(nets, ipnet)
+	}
+
+	return nets, failures
+}
+
+// containsIP returns true when one of the prefixes contains the address.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostAddresses returns the addresses of the interface units of the host,
+// including the shared virtual addresses of their VRRP groups.
+func hostAddresses(host Host) []interfaceAddress {
+	var addrs []interfaceAddress
+
+	add := func(iface string, prefixes []string, shared bool) {
+		for _, p := range prefixes {
+			ip, _, err := net.ParseCIDR(p)
+			if err != nil {
+				ip = net.ParseIP(p)
+			}
+			if ip == nil {
+				continue
+			}
+
+			addrs = append(addrs, interfaceAddress{host: host.HostName, iface: iface, ip: ip, shared: shared})
+		}
+	}
+
+	addUnit := func(iface string, inet, inet6 []string, vrrp []data.VRRPGroup) {
+		add(iface, append(append([]string(nil), inet...), inet6...), false)
+		for _, g := range vrrp {
+			add(iface, g.VirtualAddresses, true)
+		}
+	}
+
+	for _, e := range host.Data.EthernetInterfaces {
+		for i, u := range e.Units {
+			addUnit(fmt.Sprintf("%s.%d", e.Name, i), u.Inet, u.Inet6, u.VRRP)
+		}
+	}
+
+	for _, e := range host.Data.AEInterfaces {
+		for i, u := range e.Units {
+			addUnit(fmt.Sprintf("%s.%d", e.Name, i), u.Inet, u.Inet6, u.VRRP)
+		}
+	}
+
+	for _, irb := range host.Data.IRBInterfaces {
+		addUnit("irb."+irb.Unit, irb.Inet, irb.Inet6, irb.VRRP)
+	}
+
+	return addrs
+}
+
+// checkDuplicateAddresses reports addresses configured on more than one host,
+// unless the address is shared on each of them.
+func checkDuplicateAddresses(hosts []Host, addrs map[string][]interfaceAddress) []string {
+	var failures []string
+
+	seen := map[string]interfaceAddress{}
+
+	for _, h := range hosts {
+		for _, a := range addrs[h.HostName] {
+			key := a.ip.String()
+
+			first, ok := seen[key]
+			if !ok {
+				seen[key] = a
+				continue
+			}
+
+			if first.host != a.host && !(first.shared && a.shared) {
+				failures = append(failures, fmt.Sprintf("duplicate address: %s on %s and %s", key, first, a))
+			}
+		}
+	}
+
+	return failures
+}
+
+// bgpGroups returns the BGP groups of the host, including those of its routing
+// instances.
+func bgpGroups(host Host) []data.BGPGroup {
+	groups := append([]data.BGPGroup(nil), host.Data.BGP.Groups...)
+
+	for _, ri := range host.Data.Routing.Instances {
+		groups = append(groups, ri.BGP.Groups...)
+	}
+
+	return groups
+}
+
+// checkBGPNeighbors reports BGP neighbors which are addresses of another host
+// which does not list any address of the host as a neighbor, and neighbors
+// whose ASN differs from the ASN of the group.  Shared addresses do not
+// identify a single host, and are not checked.
+func checkBGPNeighbors(hosts []Host, addrs map[string][]interfaceAddress) []string {
+	var failures []string
+
+	owners := map[string]Host{}
+	ownAddresses := map[string][]string{}
+
+	for _, h := range hosts {
+		for _, a := range addrs[h.HostName] {
+			if a.shared {
+				continue
+			}
+			owners[a.ip.String()] = h
+			ownAddresses[h.HostName] = append(ownAddresses[h.HostName], a.ip.String())
+		}
+	}
+
+	for _, h := range hosts {
+		for _, g := range bgpGroups(h) {
+			for _, neighbor := range g.Neighbors {
+				ip := net.ParseIP(neighbor)
+				if ip == nil {
+					continue
+				}
+
+				peer, ok := owners[ip.String()]
+				if !ok || peer.HostName == h.HostName {
+					continue
+				}
+
+				if g.ASN != 0 && peer.Data.Routing.ASN != 0 && g.ASN != peer.Data.Routing.ASN {
+					failures = append(failures, fmt.Sprintf("bgp asn mismatch: %s group %s expects AS%d for neighbor %s, but %s is AS%d", h.HostName, g.Name, g.ASN, neighbor, peer.HostName, peer.Data.Routing.ASN))
+				}
+
+				if !peersWith(peer, ownAddresses[h.HostName]) {
+					failures = append(failures, fmt.Sprintf("bgp neighbor not reciprocated: %s peers with %s at %s, but %s does not peer with %s", h.HostName, peer.HostName, neighbor, peer.HostName, h.HostName))
+				}
+			}
+		}
+	}
+
+	return failures
+}
+
+// peersWith returns true when the host has a BGP neighbor with one of the
+// addresses.
+func peersWith(host Host, addresses []string) bool {
+	for _, g := range bgpGroups(host) {
+		for _, neighbor := range g.Neighbors {
+			ip := net.ParseIP(neighbor)
+			if ip != nil && containsString(addresses, ip.String()) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// switchedInterface returns the ethernet switching configuration of the named
+// interface of the host.
+func switchedInterface(host Host, name string) (data.EthernetSwitching, int, bool) {
+	for _, e := range host.Data.EthernetInterfaces {
+		if e.Name == name {
+			return e.EthernetSwitching, e.NativeVlanID, true
+		}
+	}
+
+	for _, e := range host.Data.AEInterfaces {
+		if e.Name == name {
+			return e.EthernetSwitching, e.NativeVlanID, true
+		}
+	}
+
+	return data.EthernetSwitching{}, 0, false
+}
+
+// linkEnd returns the host and interface of an end of a link, eg:
+// "router1:ge-0/0/0", matching the short or fully qualified name of a host.
+func linkEnd(hosts []Host, end string) (Host, string, bool) {
+	parts := strings.SplitN(end, ":", 2)
+	if len(parts) != 2 {
+		return Host{}, "", false
+	}
+
+	for _, h := range hosts {
+		if h.HostName == parts[0] || h.NetworkHost.Name == parts[0] {
+			return h, parts[1], true
+		}
+	}
+
+	return Host{}, "", false
+}
+
+// checkLinkVLANs reports the VLANs trunked on only one end of a link, and
+// links whose ends have different native VLANs.
+func checkLinkVLANs(hosts []Host, links []data.Link) []string {
+	var failures []string
+
+	for _, l := range links {
+		a, aIface, ok := linkEnd(hosts, l.A)
+		if !ok {
+			continue
+		}
+
+		b, bIface, ok := linkEnd(hosts, l.B)
+		if !ok {
+			continue
+		}
+
+		aSwitching, aNative, aOK := switchedInterface(a, aIface)
+		bSwitching, bNative, bOK := switchedInterface(b, bIface)
+
+		if !aOK || !bOK {
+			continue
+		}
+
+		for _, v := range aSwitching.VLANs {
+			if !containsString(bSwitching.VLANs, v) {
+				failures = append(failures, fmt.Sprintf("vlan mismatch: %s is trunked on %s %s but not on %s %s", v, a.HostName, aIface, b.HostName, bIface))
+			}
+		}
+
+		for _, v := range bSwitching.VLANs {
+			if !containsString(aSwitching.VLANs, v) {
+				failures = append(failures, fmt.Sprintf("vlan mismatch: %s is trunked on %s %s but not on %s %s", v, b.HostName, bIface, a.HostName, aIface))
+			}
+		}
+
+		if aNative != bNative {
+			failures = append(failures, fmt.Sprintf("native vlan mismatch: %s %s has %d but %s %s has %d", a.HostName, aIface, aNative, b.HostName, bIface, bNative))
+		}
+	}
+
+	return failures
+}
+
+// checkRouterIDs reports router IDs used by more than one host.
+func checkRouterIDs(hosts []Host) []string {
+	var failures []string
+
+	seen := map[string]string{}
+
+	for _, h := range hosts {
+		id := h.Data.Routing.RouterID
+		if id == "" {
+			continue
+		}
+
+		if first, ok := seen[id]; ok {
+			failures = append(failures, fmt.Sprintf("duplicate router id: %s on %s and %s", id, first, h.HostName))
+			continue
+		}
+
+		seen[id] = h.HostName
+	}
+
+	return failures
+}
@@ -0,0 +1,132 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func testConsistencyHost(name string, d data.HostData) Host {
+	return Host{
+		HostName:    name + ".example.com",
+		NetworkHost: &inventory.NetworkHost{Name: name, Domain: "example.com", Platform: "junos"},
+		Data:        d,
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	router1 := data.HostData{
+		EthernetInterfaces: []data.EthernetInterface{
+			{Name: "ge-0/0/0", Units: []data.InetUnit{{Inet: []string{"10.255.0.0/31"}}}},
+			{Name: "ge-0/0/1", EthernetSwitching: data.EthernetSwitching{Mode: "trunk", VLANs: []string{"users", "lab"}}},
+		},
+		IRBInterfaces: []data.IRBInterface{{Unit: "10", Inet: []string{"192.0.2.1/24"}}},
+		BGP: data.BGP{Groups: []data.BGPGroup{
+			{Name: "transit", Type: "external", ASN: 64513, Neighbors: []string{"10.255.0.1"}},
+		}},
+		Routing: data.Routing{RouterID: "10.0.0.1", ASN: 64512},
+	}
+
+	router2 := data.HostData{
+		EthernetInterfaces: []data.EthernetInterface{
+			{Name: "ge-0/0/0", Units: []data.InetUnit{{Inet: []string{"10.255.0.1/31"}}}},
+			{Name: "ge-0/0/1", EthernetSwitching: data.EthernetSwitching{Mode: "trunk", VLANs: []string{"users"}}},
+		},
+		IRBInterfaces: []data.IRBInterface{{Unit: "10", Inet: []string{"192.0.2.1/24"}}},
+		Routing:       data.Routing{RouterID: "10.0.0.1", ASN: 64514},
+	}
+
+	n := &NetConfig{
+		Hosts: []Host{
+			testConsistencyHost("router2", router2),
+			testConsistencyHost("router1", router1),
+		},
+		Data: data.Data{Links: []data.Link{
+			{A: "router1:ge-0/0/1", B: "router2.example.com:ge-0/0/1"},
+			{A: "router1:ge-0/0/2", B: "router3:ge-0/0/0"},
+		}},
+	}
+
+	require.Equal(t, []string{
+		"duplicate address: 192.0.2.1 on router1.example.com irb.10 and router2.example.com irb.10",
+		"bgp asn mismatch: router1.example.com group transit expects AS64513 for neighbor 10.255.0.1, but router2.example.com is AS64514",
+		"bgp neighbor not reciprocated: router1.example.com peers with router2.example.com at 10.255.0.1, but router2.example.com does not peer with router1.example.com",
+		"vlan mismatch: lab is trunked on router1.example.com ge-0/0/1 but not on router2.example.com ge-0/0/1",
+		"duplicate router id: 10.0.0.1 on router1.example.com and router2.example.com",
+	}, n.CheckConsistency())
+
+	// Once corrected, the hosts are consistent.
+	router2.IRBInterfaces[0].Inet = []string{"192.0.2.2/24"}
+	router2.EthernetInterfaces[1].EthernetSwitching.VLANs = []string{"users", "lab"}
+	router2.BGP.Groups = []data.BGPGroup{{Name: "transit", ASN: 64512, Neighbors: []string{"10.255.0.0"}}}
+	router2.Routing = data.Routing{RouterID: "10.0.0.2", ASN: 64513}
+
+	n.Hosts[0] = testConsistencyHost("router2", router2)
+
+	require.Empty(t, n.CheckConsistency())
+}
+
+func TestCheckConsistencyShared(t *testing.T) {
+	vrrp := []data.VRRPGroup{{Group: 1, VirtualAddresses: []string{"192.0.2.1"}}}
+
+	router1 := data.HostData{
+		IRBInterfaces: []data.IRBInterface{{Unit: "10", Inet: []string{"192.0.2.2/24"}, VRRP: vrrp}},
+		EthernetInterfaces: []data.EthernetInterface{
+			{Name: "lo0", Units: []data.InetUnit{{Inet: []string{"198.51.100.53/32", "10.0.0.1/32"}}}},
+		},
+	}
+
+	router2 := data.HostData{
+		IRBInterfaces: []data.IRBInterface{{Unit: "10", Inet: []string{"192.0.2.3/24"}, VRRP: vrrp}},
+		EthernetInterfaces: []data.EthernetInterface{
+			{Name: "lo0", Units: []data.InetUnit{{Inet: []string{"198.51.100.53/32", "10.0.0.2/32"}}}},
+		},
+	}
+
+	n := &NetConfig{
+		Hosts: []Host{
+			testConsistencyHost("router1", router1),
+			testConsistencyHost("router2", router2),
+		},
+		Data: data.Data{Anycast: []string{"198.51.100.0/24"}},
+	}
+
+	// The VRRP virtual address and the anycast address are shared.
+	require.Empty(t, n.CheckConsistency())
+
+	// A shared address is still reported when another host configures it as
+	// an address of its own.
+	router2.IRBInterfaces[0].Inet = []string{"192.0.2.1/24"}
+	n.Hosts[1] = testConsistencyHost("router2", router2)
+
+	n.Data.Anycast = []string{"198.51.100.53", "bogus"}
+
+	require.Equal(t, []string{
+		`invalid anycast address "bogus"`,
+		"duplicate address: 192.0.2.1 on router1.example.com irb.10 and router2.example.com irb.10",
+	}, n.CheckConsistency())
+}
+
+func TestConfigureNetworkInconsistent(t *testing.T) {
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Preflight: PreflightConfig{Enabled: true}}, device)
+
+	n.Hosts[0].Data.Routing.RouterID = "10.0.0.1"
+	n.Hosts = append(n.Hosts, testConsistencyHost("router2", data.HostData{Routing: data.Routing{RouterID: "10.0.0.1"}}))
+
+	// The run is refused before any host is locked.
+	err := n.ConfigureNetwork()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate router id: 10.0.0.1 on router1.example.com and router2.example.com")
+	require.Empty(t, device.RPCs())
+
+	report := n.Report()
+	require.Len(t, report.Hosts, 2)
+	for _, r := range report.Hosts {
+		require.Error(t, r.Err)
+		require.False(t, r.Committed)
+	}
+}
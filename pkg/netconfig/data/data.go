@@ -2,10 +2,123 @@ package data
 
 // Data is the structure of the data directory.
 type Data struct {
-	TemplateDir   string   `yaml:"template_dir"`
-	TemplatePaths []string `yaml:"template_paths"`
-	DataDir       string   `yaml:"data_dir"`
-	Hierarchy     []string `yaml:"hierarchy"`
+	TemplateDir   string      `yaml:"template_dir"`
+	TemplatePaths []string    `yaml:"template_paths"`
+	DataDir       string      `yaml:"data_dir"`
+	Hierarchy     []string    `yaml:"hierarchy"`
+	GoldenDir     string      `yaml:"golden_dir"`
+	TestHosts     []TestHost  `yaml:"test_hosts"`
+	Assertions    []Assertion `yaml:"assertions"`
+	Checks        []Check     `yaml:"checks"`
+
+	InventoryNetworks []InventoryNetwork `yaml:"inventory_networks"`
+
+	Pools           []Pool `yaml:"pools"`
+	AllocationsFile string `yaml:"allocations_file"`
+
+	Links        []Link `yaml:"links"`
+	TopologyFile string `yaml:"topology_file"`
+
+	// Anycast are the addresses and prefixes which are intentionally
+	// configured on more than one host.
+	Anycast []string `yaml:"anycast"`
+}
+
+// Topology is the structure of the topology file.
+type Topology struct {
+	Links []Link `yaml:"links"`
+}
+
+// Link is a cable between the interfaces of two hosts.  Each end is the name
+// of a host and an interface, eg: "router1:ge-0/0/0".  The interfaces of both
+// ends are generated from the type of the link: a "p2p" link addresses each
+// end from the Subnet and Subnet6, or from a subnet allocated from the Pool,
+// while a "trunk" or "access" link switches the VLANs.
+type Link struct {
+	A            string   `yaml:"a"`
+	B            string   `yaml:"b"`
+	Type         string   `yaml:"type"`
+	Description  string   `yaml:"description"`
+	MTU          int      `yaml:"mtu"`
+	VLANs        []string `yaml:"vlans"`
+	NativeVlanID int      `yaml:"native_vlan_id"`
+	Subnet       string   `yaml:"subnet"`
+	Subnet6      string   `yaml:"subnet6"`
+	Pool         string   `yaml:"pool"`
+}
+
+// Pool is a range of resources from which values are allocated to keys.  The
+// range of a "prefix" pool is a CIDR divided into prefixes of Size, eg: /31
+// point-to-point links, of an "address" pool a CIDR of single addresses, eg:
+// loopbacks, and of an "integer" pool an inclusive range such as
+// "64512-65534", eg: VLAN IDs or ASNs.
+type Pool struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	Range string `yaml:"range"`
+	Size  int    `yaml:"size"`
+}
+
+// InventoryNetwork routes an L3Network of the inventory on the hosts matching
+// the roles and hosts, or on every host when neither are given.  A VLAN, an IRB
+// interface with the gateway of each inet and inet6 network, and optionally
+// router advertisements of the inet6 networks, are derived from it.  The unit
+// defaults to the VLAN ID, and the VLAN name to the name of the L3Network.
+type InventoryNetwork struct {
+	L3Network           string   `yaml:"l3_network"`
+	VLANID              int      `yaml:"vlan_id"`
+	VLANName            string   `yaml:"vlan_name"`
+	Unit                string   `yaml:"unit"`
+	MTU                 int      `yaml:"mtu"`
+	Roles               []string `yaml:"roles"`
+	Hosts               []string `yaml:"hosts"`
+	RouterAdvertisement bool     `yaml:"router_advertisement"`
+	Managed             bool     `yaml:"managed"`
+	DNSServer           string   `yaml:"dns_server"`
+}
+
+// Assertion is a check of the rendered configuration of the hosts matching
+// the roles and hosts, or of every host when neither are given.  Each of
+// Contains and NotContains is a hierarchy such as "system services ssh",
+// whose words may be glob patterns.  When Each is set, the hierarchies are
+// checked below every stanza matching Each, eg: "interfaces ge-*".
+type Assertion struct {
+	Name        string   `yaml:"name"`
+	Roles       []string `yaml:"roles"`
+	Hosts       []string `yaml:"hosts"`
+	Each        string   `yaml:"each"`
+	Contains    []string `yaml:"contains"`
+	NotContains []string `yaml:"not_contains"`
+}
+
+// Check is an operational check of the hosts matching the roles and hosts, or
+// of every host when neither are given, whose state is captured before and
+// after a change is committed.  The Type is one of "bgp" for the established
+// BGP peers, "interfaces" for the interfaces which are up, "lacp" for the
+// active members of aggregated ethernet interfaces, or "routes" for the number
+// of active routes in the Table, which may drop by up to Tolerance.
+type Check struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Roles     []string `yaml:"roles"`
+	Hosts     []string `yaml:"hosts"`
+	Table     string   `yaml:"table"`
+	Tolerance int      `yaml:"tolerance"`
+}
+
+// TestHost is a fixture host whose rendered configuration is compared against
+// a golden file by the test command.
+type TestHost struct {
+	Name            string   `yaml:"name"`
+	Domain          string   `yaml:"domain"`
+	Role            string   `yaml:"role"`
+	Group           string   `yaml:"group"`
+	Platform        string   `yaml:"platform"`
+	Type            string   `yaml:"type"`
+	OperatingSystem string   `yaml:"operating_system"`
+	Description     string   `yaml:"description"`
+	InetAddress     []string `yaml:"inet_address"`
+	Inet6Address    []string `yaml:"inet6_address"`
 }
 
 // HostData is the data relating to a particular host.
@@ -17,6 +130,7 @@ type HostData struct {
 	EthernetInterfaces    []EthernetInterface   `yaml:"eth_interfaces"`
 	IRBInterfaces         []IRBInterface        `yaml:"irb_interfaces"`
 	LLDPInterfaces        []string              `yaml:"lldp_interfaces"`
+	MaintenanceWindow     string                `yaml:"maintenance_window"`
 	NTPServers            []string              `yaml:"ntp_servers"`
 	RouterAdvertisements  []RouterAdvertisement `yaml:"router_advertisements"`
 	Routing               Routing               `yaml:"routing"`
@@ -156,17 +270,27 @@ type RouterAdvertisement struct {
 
 // IRBInterface is an Integrated Bridging and Routing interface for a Juniper router.
 type IRBInterface struct {
-	Unit  string   `yaml:"unit"`
-	Inet  []string `yaml:"inet"`
-	Inet6 []string `yaml:"inet6"`
-	MTU   int      `yaml:"mtu"`
+	Unit  string      `yaml:"unit"`
+	Inet  []string    `yaml:"inet"`
+	Inet6 []string    `yaml:"inet6"`
+	MTU   int         `yaml:"mtu"`
+	VRRP  []VRRPGroup `yaml:"vrrp"`
 }
 
 // InetUnit is a single interface unit for a Juniper device.
 type InetUnit struct {
-	Inet  []string `yaml:"inet"`
-	Inet6 []string `yaml:"inet6"`
-	MTU   int      `yaml:"mtu"`
+	Inet  []string    `yaml:"inet"`
+	Inet6 []string    `yaml:"inet6"`
+	MTU   int         `yaml:"mtu"`
+	VRRP  []VRRPGroup `yaml:"vrrp"`
+}
+
+// VRRPGroup is a VRRP group of an interface unit, whose virtual addresses are
+// shared by the routers of the group.
+type VRRPGroup struct {
+	Group            int      `yaml:"group"`
+	Priority         int      `yaml:"priority"`
+	VirtualAddresses []string `yaml:"virtual_addresses"`
 }
 
 // AEInterface is an aggregated eithernet interface on a Juniper device.
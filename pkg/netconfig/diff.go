@@ -0,0 +1,31 @@
+package netconfig
+
+import "strings"
+
+// ANSI escape sequences used to colorize diffs.
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// ColorizeDiff returns the Junos configuration diff with added lines in
+// green, removed lines in red and hierarchy markers in cyan, for display on a
+// terminal.
+func ColorizeDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			lines[i] = colorGreen + line + colorReset
+		case strings.HasPrefix(line, "-"):
+			lines[i] = colorRed + line + colorReset
+		case strings.HasPrefix(line, "[edit"):
+			lines[i] = colorCyan + line + colorReset
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
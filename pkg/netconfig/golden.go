@@ -0,0 +1,144 @@
+package netconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/xaque208/znet/modules/inventory"
+)
+
+// defaultGoldenDir is the directory within the data directory which holds
+// the golden files, when none is configured.
+const defaultGoldenDir = "golden"
+
+// GoldenResult is the result of comparing the rendered configuration of a
+// host with its golden file.
+type GoldenResult struct {
+	Host    string
+	Path    string
+	Diff    string
+	Missing bool
+	Updated bool
+}
+
+// Passed returns true when the rendered configuration matched the golden
+// file, or the golden file was updated.
+func (r GoldenResult) Passed() bool {
+	return r.Updated || (!r.Missing && r.Diff == "")
+}
+
+// NewForTestHosts is used to build a new *NetConfig from the test hosts
+// defined in the data directory, without consulting the inventory.
+func NewForTestHosts(cfg Config, logger log.Logger) (*NetConfig, error) {
+	d, err := loadData(cfg.Data.Directory, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]inventory.NetworkHost, len(d.TestHosts))
+	for i, t := range d.TestHosts {
+		platform := t.Platform
+		if platform == "" {
+			platform = "junos"
+		}
+
+		hosts[i] = inventory.NetworkHost{
+			Name:            t.Name,
+			Domain:          t.Domain,
+			Role:            t.Role,
+			Group:           t.Group,
+			Platform:        platform,
+			Type:            t.Type,
+			OperatingSystem: t.OperatingSystem,
+			Description:     t.Description,
+			InetAddress:     t.InetAddress,
+			Inet6Address:    t.Inet6Address,
+		}
+	}
+
+	return NewWithHosts(cfg, hosts, logger)
+}
+
+// TestGolden renders the configuration of each host and compares it with the
+// golden file for the host.  When update is true, the golden files are
+// written with the rendered configuration instead.
+func (n *NetConfig) TestGolden(update bool) ([]GoldenResult, error) {
+	var results []GoldenResult
+
+	for _, host := range n.Hosts {
+		result, err := n.testGoldenHost(host, update)
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (n *NetConfig) goldenPath(host Host) string {
+	dir := n.Data.GoldenDir
+	if dir == "" {
+		dir = defaultGoldenDir
+	}
+
+	return filepath.Join(n.cfg.Data.Directory, dir, host.HostName+".conf")
+}
+
+func (n *NetConfig) testGoldenHost(host Host, update bool) (GoldenResult, error) {
+	result := GoldenResult{
+		Host: host.HostName,
+		Path: n.goldenPath(host),
+	}
+
+	rendered, err := n.RenderHost(host)
+	if err != nil {
+		return result, err
+	}
+
+	if update {
+		err = os.MkdirAll(filepath.Dir(result.Path), 0750)
+		if err != nil {
+			return result, err
+		}
+
+		err = os.WriteFile(result.Path, []byte(rendered), 0600)
+		if err != nil {
+			return result, err
+		}
+
+		result.Updated = true
+
+		return result, nil
+	}
+
+	golden, err := os.ReadFile(result.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.Missing = true
+			return result, nil
+		}
+		return result, err
+	}
+
+	if string(golden) == rendered {
+		return result, nil
+	}
+
+	result.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(golden)),
+		B:        difflib.SplitLines(rendered),
+		FromFile: result.Path,
+		ToFile:   "rendered",
+		Context:  3,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to diff %s: %w", result.Path, err)
+	}
+
+	return result, nil
+}
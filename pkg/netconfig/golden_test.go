@@ -0,0 +1,54 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGolden(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data.yaml"] += `
+test_hosts:
+  - name: router1
+    domain: example.com
+    role: core
+`
+
+	dir := writeTestData(t, files)
+
+	n, err := NewForTestHosts(Config{Data: DataConfig{Directory: dir}}, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, n.Hosts, 1)
+
+	results, err := n.TestGolden(false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.True(t, results[0].Missing)
+	require.False(t, results[0].Passed())
+
+	results, err = n.TestGolden(true)
+	require.NoError(t, err)
+	require.True(t, results[0].Updated)
+
+	path := filepath.Join(dir, "golden", "router1.example.com.conf")
+	require.Equal(t, path, results[0].Path)
+
+	results, err = n.TestGolden(false)
+	require.NoError(t, err)
+	require.True(t, results[0].Passed())
+
+	require.NoError(t, os.WriteFile(path, []byte("system {\n    host-name router2;\n}\n"), 0600))
+
+	results, err = n.TestGolden(false)
+	require.NoError(t, err)
+	require.False(t, results[0].Passed())
+	require.Contains(t, results[0].Diff, "-    host-name router2;")
+	require.Contains(t, results[0].Diff, "+    host-name router1;")
+}
@@ -0,0 +1,166 @@
+package netconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log/level"
+)
+
+// diffSummary is the size of a candidate diff, and the statements it adds and
+// removes.
+type diffSummary struct {
+	added   int
+	removed int
+
+	addedStatements   []statement
+	removedStatements []statement
+}
+
+// record adds the path of a statement changed by the diff.
+func (s *diffSummary) record(sign byte, path statement) {
+	switch sign {
+	case '+':
+		s.addedStatements = append(s.addedStatements, path)
+	case '-':
+		s.removedStatements = append(s.removedStatements, path)
+	}
+}
+
+// changed returns the statements added or removed by the diff.
+func (s diffSummary) changed() []statement {
+	return append(append([]statement{}, s.addedStatements...), s.removedStatements...)
+}
+
+// summarizeDiff counts the lines added and removed by a diff in the format of
+// "show | compare", and records the path of each changed statement.
+func summarizeDiff(diff string) diffSummary {
+	var (
+		s      diffSummary
+		header []string
+		stack  [][]string
+	)
+
+	for _, line := range strings.Split(diff, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "[edit") && strings.HasSuffix(trimmed, "]") {
+			header = strings.Fields(strings.TrimSuffix(strings.TrimPrefix(trimmed, "[edit"), "]"))
+			stack = nil
+			continue
+		}
+
+		sign := byte(' ')
+		if len(line) > 0 && (line[0] == '+' || line[0] == '-') {
+			sign = line[0]
+			line = line[1:]
+		}
+
+		switch sign {
+		case '+':
+			s.added++
+		case '-':
+			s.removed++
+		}
+
+		tokens, err := tokenizeConfig(line)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+
+		var words []string
+		for _, t := range tokens {
+			switch t {
+			case "{":
+				stack = append(stack, words)
+				s.record(sign, diffPath(header, stack, nil))
+				words = nil
+			case "}":
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			case ";":
+				if len(words) > 0 {
+					s.record(sign, diffPath(header, stack, words))
+				}
+				words = nil
+			case "replace:", "inactive:", "active:", "protect:", "...":
+				continue
+			default:
+				words = append(words, t)
+			}
+		}
+	}
+
+	return s
+}
+
+func diffPath(header []string, stack [][]string, leaf []string) statement {
+	path := append(statement{}, header...)
+	for _, words := range stack {
+		path = append(path, words...)
+	}
+
+	return append(path, leaf...)
+}
+
+// checkGuardrails returns an error when the diff of a host exceeds the
+// guardrails, unless they are overridden.  The size of the diff is counted
+// against the limits of the run until released.
+func (n *NetConfig) checkGuardrails(host Host, s diffSummary) error {
+	g := n.cfg.Guardrails
+
+	var violations []string
+
+	if g.MaxAdded > 0 && s.added > g.MaxAdded {
+		violations = append(violations, fmt.Sprintf("adds %d lines, more than the maximum of %d", s.added, g.MaxAdded))
+	}
+
+	if g.MaxRemoved > 0 && s.removed > g.MaxRemoved {
+		violations = append(violations, fmt.Sprintf("removes %d lines, more than the maximum of %d", s.removed, g.MaxRemoved))
+	}
+
+	for _, p := range g.Protected {
+		patterns := strings.Fields(p)
+		for _, r := range s.removedStatements {
+			if r.hasPrefix(patterns) {
+				violations = append(violations, fmt.Sprintf("removes %q from protected hierarchy %q", r.String(), p))
+				break
+			}
+		}
+	}
+
+	n.guardMtx.Lock()
+	defer n.guardMtx.Unlock()
+
+	if g.MaxRunAdded > 0 && n.runAdded+s.added > g.MaxRunAdded {
+		violations = append(violations, fmt.Sprintf("would add %d lines during the run, more than the maximum of %d", n.runAdded+s.added, g.MaxRunAdded))
+	}
+
+	if g.MaxRunRemoved > 0 && n.runRemoved+s.removed > g.MaxRunRemoved {
+		violations = append(violations, fmt.Sprintf("would remove %d lines during the run, more than the maximum of %d", n.runRemoved+s.removed, g.MaxRunRemoved))
+	}
+
+	if len(violations) > 0 {
+		if !n.cfg.OverrideGuardrails {
+			return fmt.Errorf("guardrails exceeded on %s, use -override-guardrails to commit: %s", host.HostName, strings.Join(violations, "; "))
+		}
+
+		_ = level.Warn(n.logger).Log("msg", "overriding guardrails", "host", host.HostName, "violations", strings.Join(violations, "; "))
+	}
+
+	n.runAdded += s.added
+	n.runRemoved += s.removed
+
+	return nil
+}
+
+// releaseGuardrails returns the size of a diff which was not committed to the
+// limits of the run.
+func (n *NetConfig) releaseGuardrails(s diffSummary) {
+	n.guardMtx.Lock()
+	defer n.guardMtx.Unlock()
+
+	n.runAdded -= s.added
+	n.runRemoved -= s.removed
+}
@@ -0,0 +1,158 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+)
+
+const testGuardrailDiff = `
+[edit system login]
+-    user ops {
+-        class super-user;
+-    }
+[edit system ntp]
+-  server 10.0.0.1;
++  server 10.0.0.2;
++  server 10.0.0.3;
+[edit interfaces]
+-   fxp0 {
+-       unit 0 {
+-           family inet {
+-               address 192.0.2.10/24;
+-           }
+-       }
+-   }
+`
+
+func TestSummarizeDiff(t *testing.T) {
+	s := summarizeDiff(testGuardrailDiff)
+
+	require.Equal(t, 2, s.added)
+	require.Equal(t, 11, s.removed)
+
+	var removed []string
+	for _, r := range s.removedStatements {
+		removed = append(removed, r.String())
+	}
+
+	require.Equal(t, []string{
+		"system login user ops",
+		"system login user ops class super-user",
+		"system ntp server 10.0.0.1",
+		"interfaces fxp0",
+		"interfaces fxp0 unit 0",
+		"interfaces fxp0 unit 0 family inet",
+		"interfaces fxp0 unit 0 family inet address 192.0.2.10/24",
+	}, removed)
+}
+
+func TestCheckGuardrails(t *testing.T) {
+	host := Host{HostName: "router1.example.com", NetworkHost: &inventory.NetworkHost{Name: "router1"}}
+	s := summarizeDiff(testGuardrailDiff)
+
+	cases := map[string]struct {
+		guardrails GuardrailConfig
+		override   bool
+		errs       []string
+	}{
+		"unlimited": {},
+		"max added": {
+			guardrails: GuardrailConfig{MaxAdded: 1},
+			errs:       []string{"adds 2 lines"},
+		},
+		"max removed": {
+			guardrails: GuardrailConfig{MaxRemoved: 10},
+			errs:       []string{"removes 11 lines"},
+		},
+		"protected": {
+			guardrails: GuardrailConfig{Protected: defaultProtected},
+			errs:       []string{`protected hierarchy "system login"`, `protected hierarchy "interfaces fxp0"`},
+		},
+		"unchanged protected": {
+			guardrails: GuardrailConfig{Protected: []string{"system services ssh"}},
+		},
+		"override": {
+			guardrails: GuardrailConfig{MaxAdded: 1, Protected: defaultProtected},
+			override:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := &NetConfig{
+				logger: log.NewNopLogger(),
+				cfg:    &Config{Guardrails: tc.guardrails, OverrideGuardrails: tc.override},
+			}
+
+			err := n.checkGuardrails(host, s)
+			if len(tc.errs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, e := range tc.errs {
+				require.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+func TestCheckGuardrailsRun(t *testing.T) {
+	host := Host{HostName: "router1.example.com", NetworkHost: &inventory.NetworkHost{Name: "router1"}}
+	s := summarizeDiff(testGuardrailDiff)
+
+	n := &NetConfig{
+		logger: log.NewNopLogger(),
+		cfg:    &Config{Guardrails: GuardrailConfig{MaxRunAdded: 5}},
+	}
+
+	require.NoError(t, n.checkGuardrails(host, s))
+	require.NoError(t, n.checkGuardrails(host, s))
+	require.Error(t, n.checkGuardrails(host, s))
+
+	// A diff which was not committed no longer counts against the run.
+	n.releaseGuardrails(s)
+	require.NoError(t, n.checkGuardrails(host, s))
+}
+
+func TestConfigureNetworkGuardrails(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data/host/router1.yaml"] = `
+ntp_servers:
+  - 10.0.0.2
+  - 10.0.0.3
+`
+	dataCfg := DataConfig{Directory: writeTestData(t, files)}
+
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Data: dataCfg, Guardrails: GuardrailConfig{MaxAdded: 1}}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+	require.Empty(t, device.Commits())
+	require.Equal(t, device.Running(), device.Candidate())
+
+	n = newTestNetConfig(t, Config{Commit: true, Data: dataCfg, Guardrails: GuardrailConfig{MaxAdded: 1}, OverrideGuardrails: true}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.Len(t, device.Commits(), 1)
+}
+
+func TestConfigureNetworkGuardrailsReleased(t *testing.T) {
+	// The host has no maintenance window, so its commit fails once the
+	// guardrails of the run are reserved.
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, CommitAt: commitAtWindow, Guardrails: GuardrailConfig{MaxRunAdded: 10}}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+	require.Empty(t, device.Commits())
+	require.Equal(t, device.Running(), device.Candidate())
+	require.Zero(t, n.runAdded)
+	require.Zero(t, n.runRemoved)
+}
@@ -0,0 +1,58 @@
+package netconfig
+
+import (
+	"strings"
+
+	"github.com/scottdware/go-junos"
+)
+
+// CommitRecord is a single entry in the commit history of a host.
+type CommitRecord struct {
+	Sequence   int
+	Timestamp  string
+	User       string
+	Client     string
+	Comment    string
+	Netconfig  bool
+	Provenance Provenance
+}
+
+// CommitHistory returns the commit history of a host, most recent first,
+// identifying which of the commits were made by netconfig.
+func (n *NetConfig) CommitHistory(host Host) ([]CommitRecord, error) {
+	session, err := n.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	return commitHistory(session)
+}
+
+func commitHistory(session *junos.Junos) ([]CommitRecord, error) {
+	history, err := session.CommitHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]CommitRecord, 0, len(history.Entries))
+	for _, e := range history.Entries {
+		comment := strings.TrimSpace(e.Log)
+		if comment == "" {
+			comment = strings.TrimSpace(e.Comment)
+		}
+
+		r := CommitRecord{
+			Sequence:  e.Sequence,
+			Timestamp: strings.TrimSpace(e.Timestamp),
+			User:      strings.TrimSpace(e.User),
+			Client:    strings.TrimSpace(e.Method),
+			Comment:   comment,
+		}
+		r.Provenance, r.Netconfig = ParseCommitComment(comment)
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,166 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+)
+
+// configureIncremental configures the hosts whose rendered configuration
+// changed since it was last applied, as recorded in the state file, skipping
+// the others, and records the hosts which were applied.
+func (n *NetConfig) configureIncremental() error {
+	state, err := LoadState(n.cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	changed, unchanged, hashes := n.changedHosts(state)
+
+	err = n.configureHosts(changed, unchanged)
+
+	n.recordApplied(state, hashes, n.Report())
+
+	if saveErr := state.Save(n.cfg.StateFile); saveErr != nil {
+		_ = level.Error(n.logger).Log("msg", "failed to save state", "err", saveErr)
+		if err == nil {
+			err = saveErr
+		}
+	}
+
+	return err
+}
+
+// changedHosts renders the junos hosts, returning those whose rendered
+// configuration differs from that recorded in the state, those which match
+// it, and the hash of the rendered configuration of each host.  A host which
+// fails to render is changed, so that the failure is reported by the run, and
+// every host is changed when forced.
+func (n *NetConfig) changedHosts(state *State) (changed, unchanged []Host, hashes map[string]string) {
+	hashes = map[string]string{}
+
+	var hosts []Host
+	for _, h := range n.Hosts {
+		if h.NetworkHost.Platform == "junos" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	rendered, errs := n.renderHosts(hosts)
+
+	for i, h := range hosts {
+		if errs[i] == nil {
+			hashes[h.HostName] = renderedHash(rendered[i])
+
+			if s, ok := state.Hosts[h.HostName]; ok && s.Hash == hashes[h.HostName] && !n.cfg.Force {
+				unchanged = append(unchanged, h)
+				continue
+			}
+		}
+
+		changed = append(changed, h)
+	}
+
+	return changed, unchanged, hashes
+}
+
+// recordApplied records in the state the hosts of the report whose
+// configuration was applied: those which were committed, and those which
+// already matched the rendered configuration.  A commit confirmed which was
+// left unconfirmed is not recorded, as the device may yet roll it back.
+func (n *NetConfig) recordApplied(state *State, hashes map[string]string, report RunReport) {
+	for _, r := range report.Hosts {
+		if r.Err != nil || r.Skipped || (r.Changed && !r.Committed) || r.Unconfirmed {
+			continue
+		}
+
+		hash, ok := hashes[r.Host]
+		if !ok {
+			continue
+		}
+
+		state.Hosts[r.Host] = HostState{
+			Revision: n.provenance.Revision,
+			Hash:     hash,
+			Applied:  report.Finished,
+		}
+	}
+}
+
+// forgetHost removes a host from the state file, so that it is pushed by the
+// next run after its configuration was changed by other means, eg: a rollback.
+func (n *NetConfig) forgetHost(host Host) {
+	if n.cfg.StateFile == "" {
+		return
+	}
+
+	n.stateMtx.Lock()
+	defer n.stateMtx.Unlock()
+
+	state, err := LoadState(n.cfg.StateFile)
+	if err == nil {
+		if _, ok := state.Hosts[host.HostName]; !ok {
+			return
+		}
+
+		delete(state.Hosts, host.HostName)
+		err = state.Save(n.cfg.StateFile)
+	}
+
+	if err != nil {
+		_ = level.Error(n.logger).Log("msg", "failed to remove host from state", "host", host.HostName, "err", err)
+	}
+}
+
+// skipHost reports a host as skipped.
+func (n *NetConfig) skipHost(host Host) {
+	_ = level.Info(n.logger).Log("msg", "skipping unchanged host", "host", host.HostName)
+
+	n.updateResult(host, func(r *HostResult) { r.Skipped = true })
+	n.finishHost(host, nil)
+}
+
+// checkDrift diffs the rendered configuration of a skipped host against the
+// device, reporting any drift without committing it.
+func (n *NetConfig) checkDrift(ctx context.Context, host Host) (err error) {
+	ctx, span := tracer().Start(ctx, "CheckDrift", hostAttributes(host))
+	defer func() {
+		endSpan(span, err)
+		n.finishHost(host, err)
+	}()
+
+	n.updateResult(host, func(r *HostResult) { r.Skipped = true })
+
+	n.progress(host, phaseRender)
+
+	rendered, err := n.renderHost(ctx, host)
+	if err != nil {
+		return recordFailure(host, phaseRender, err)
+	}
+
+	session, closeSession, err := n.openCandidate(ctx, host, rendered)
+	if err != nil {
+		return err
+	}
+	defer closeSession()
+
+	n.progress(host, phaseDiff)
+
+	diff, err := session.Diff(0)
+	if err != nil {
+		return recordFailure(host, phaseDiff, err)
+	}
+
+	if len(diff) > 1 {
+		_ = level.Warn(n.logger).Log("msg", "configuration drifted", "host", host.HostName)
+		fmt.Printf("%+v", diff)
+
+		n.updateResult(host, func(r *HostResult) {
+			r.Diff = diff
+			r.Drifted = true
+		})
+	}
+
+	return discardCandidate(session)
+}
@@ -0,0 +1,140 @@
+package netconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xaque208/netconfig/pkg/junostest"
+)
+
+// commitOutOfBand commits a configuration to the device outside of netconfig.
+func commitOutOfBand(t *testing.T, device *junostest.Server, config string) {
+	session, err := device.Dial()
+	require.NoError(t, err)
+	defer session.Close()
+
+	require.NoError(t, loadConfigText(session, "override", config))
+	require.NoError(t, commit(session, "out of band", 0))
+}
+
+func TestConfigureNetworkIncremental(t *testing.T) {
+	device := newTestDevice(t)
+	dir := writeTestData(t, testDataFiles)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{Data: DataConfig{Directory: dir}, StateFile: stateFile, Commit: true}
+
+	n := newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+	require.Len(t, device.Commits(), 1)
+
+	state, err := LoadState(stateFile)
+	require.NoError(t, err)
+	require.Contains(t, state.Hosts, "router1.example.com")
+
+	// The unchanged host is skipped without opening a session.
+	rpcs := len(device.RPCs())
+
+	n = newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	report := n.Report()
+	require.Len(t, report.Hosts, 1)
+	require.True(t, report.Hosts[0].Skipped)
+	require.Equal(t, rpcs, len(device.RPCs()))
+
+	// Drift is reported, but not corrected.
+	commitOutOfBand(t, device, testRunningConfig)
+
+	cfg.CheckDrift = true
+	n = newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	report = n.Report()
+	require.True(t, report.Hosts[0].Skipped)
+	require.True(t, report.Hosts[0].Drifted)
+	require.Contains(t, report.Hosts[0].Diff, "server 10.0.0.2;")
+	require.Len(t, device.Commits(), 2)
+	require.False(t, device.Locked())
+
+	// A forced run pushes the unchanged host.
+	cfg.CheckDrift = false
+	cfg.Force = true
+	n = newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	report = n.Report()
+	require.False(t, report.Hosts[0].Skipped)
+	require.True(t, report.Hosts[0].Committed)
+	require.Len(t, device.Commits(), 3)
+}
+
+func TestForgetHost(t *testing.T) {
+	device := newTestDevice(t)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	n := newTestNetConfig(t, Config{StateFile: stateFile, Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	n.forgetHost(n.Hosts[0])
+
+	state, err := LoadState(stateFile)
+	require.NoError(t, err)
+	require.Empty(t, state.Hosts)
+}
+
+func TestConfigureNetworkIncrementalUnconfirmed(t *testing.T) {
+	device := newTestDevice(t)
+	device.ConfirmUnit = 20 * time.Millisecond
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{StateFile: stateFile, Commit: true, CommitConfirmed: 5}
+
+	n := newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+	require.True(t, n.Report().Hosts[0].Committed)
+	require.True(t, n.Report().Hosts[0].Unconfirmed)
+
+	// The commit left for the operator to confirm is not recorded.
+	state, err := LoadState(stateFile)
+	require.NoError(t, err)
+	require.NotContains(t, state.Hosts, "router1.example.com")
+
+	// Once the device rolls back, the next run pushes the host again.
+	require.Eventually(t, func() bool { return !device.ConfirmPending() }, 5*time.Second, 10*time.Millisecond)
+	require.NotContains(t, device.Running(), "server 10.0.0.2;")
+
+	n = newTestNetConfig(t, cfg, device)
+	require.NoError(t, n.ConfigureNetwork())
+	require.False(t, n.Report().Hosts[0].Skipped)
+	require.True(t, n.Report().Hosts[0].Committed)
+}
+
+func TestConfigureNetworkIncrementalDefault(t *testing.T) {
+	var cfg Config
+	cfg.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.PanicOnError))
+	require.Empty(t, cfg.StateFile)
+
+	// Without a state file, every run pushes every host and no state is
+	// written to the working directory.
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	n = newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+	require.False(t, n.Report().Hosts[0].Skipped)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
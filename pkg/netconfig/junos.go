@@ -0,0 +1,209 @@
+package netconfig
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"github.com/pkg/errors"
+	"github.com/scottdware/go-junos"
+)
+
+// RPCs not provided by go-junos.
+const (
+	rpcClearCommit      = "<clear-system-commit/>"
+	rpcCloseConfig      = "<close-configuration/>"
+	rpcCommitAtLog      = "<commit-configuration><at-time>%s</at-time><log>%s</log></commit-configuration>"
+	rpcCommitLog        = "<commit-configuration><log>%s</log></commit-configuration>"
+	rpcCommitConfirmLog = "<commit-configuration><confirmed/><confirm-timeout>%d</confirm-timeout><log>%s</log></commit-configuration>"
+	rpcGetConfigText    = "<get-configuration database=\"committed\" format=\"text\"/>"
+	rpcLoadRollback     = "<load-configuration rollback=\"%d\"/>"
+	rpcLoadConfigText   = "<load-configuration action=\"%s\" format=\"text\"><configuration-text>%s</configuration-text></load-configuration>"
+	rpcOpenPrivate      = "<open-configuration><private/></open-configuration>"
+)
+
+type commitError struct {
+	Path    string `xml:"error-path"`
+	Element string `xml:"error-info>bad-element"`
+	Message string `xml:"error-message"`
+}
+
+type commitRoutingEngine struct {
+	Name    string        `xml:"name"`
+	Success *struct{}     `xml:"commit-success"`
+	Errors  []commitError `xml:"rpc-error"`
+}
+
+type commitResults struct {
+	XMLName        xml.Name              `xml:"commit-results"`
+	Errors         []commitError         `xml:"rpc-error"`
+	RoutingEngines []commitRoutingEngine `xml:"routing-engine"`
+}
+
+type configurationText struct {
+	XMLName xml.Name `xml:"configuration-text"`
+	Text    string   `xml:",chardata"`
+}
+
+// execRPC executes a raw RPC on the session and returns the reply data, or
+// the first error reported by the device.
+func execRPC(session *junos.Junos, rpc string) (string, error) {
+	reply, err := session.Session.Exec(netconf.RawMethod(rpc))
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range reply.Errors {
+		if m.Severity == "warning" {
+			continue
+		}
+		return "", errors.New(strings.TrimSpace(m.Message))
+	}
+
+	return reply.Data, nil
+}
+
+// getConfigText returns the committed configuration of the device in text
+// format, unescaped and suitable for loading back onto a device.
+func getConfigText(session *junos.Junos) (string, error) {
+	data, err := execRPC(session, rpcGetConfigText)
+	if err != nil {
+		return "", err
+	}
+
+	var c configurationText
+	err = xml.Unmarshal([]byte(data), &c)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse configuration")
+	}
+
+	return c.Text, nil
+}
+
+// uncommittedChanges returns the differences between the shared candidate and
+// the active configuration, made by users who have not committed them.
+func uncommittedChanges(session *junos.Junos) (string, error) {
+	diff, err := session.Diff(0)
+	if err != nil {
+		return "", err
+	}
+
+	if len(diff) <= 1 {
+		return "", nil
+	}
+
+	return diff, nil
+}
+
+// openPrivate opens a private candidate configuration database, in which the
+// changes of the session are kept apart from those of other users.
+func openPrivate(session *junos.Junos) error {
+	_, err := execRPC(session, rpcOpenPrivate)
+	return err
+}
+
+// closePrivate closes the private candidate, discarding its uncommitted
+// changes.
+func closePrivate(session *junos.Junos) error {
+	_, err := execRPC(session, rpcCloseConfig)
+	return err
+}
+
+// discardCandidate discards the changes to the candidate configuration by
+// loading rollback 0.
+func discardCandidate(session *junos.Junos) error {
+	_, err := execRPC(session, fmt.Sprintf(rpcLoadRollback, 0))
+	return err
+}
+
+// loadConfigText loads the text configuration into the candidate using the
+// given load action, ie "merge", "replace" or "override".
+func loadConfigText(session *junos.Junos, action, text string) error {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(text))
+	if err != nil {
+		return err
+	}
+
+	_, err = execRPC(session, fmt.Sprintf(rpcLoadConfigText, action, buf.String()))
+	return err
+}
+
+// commit commits the candidate configuration with the given comment.  When
+// confirm is greater than zero, a commit confirmed is performed which the
+// device will roll back after confirm minutes unless confirmed.
+func commit(session *junos.Junos, comment string, confirm int) error {
+	_, err := commitRoutingEngines(session, comment, confirm)
+	return err
+}
+
+// commitRoutingEngines commits the candidate configuration as commit, and
+// returns the result of the commit on each routing engine.  The commit is
+// synchronized to every routing engine of a device with more than one.
+func commitRoutingEngines(session *junos.Junos, comment string, confirm int) ([]RoutingEngineResult, error) {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(comment))
+	if err != nil {
+		return nil, err
+	}
+
+	rpc := fmt.Sprintf(rpcCommitLog, buf.String())
+	if confirm > 0 {
+		rpc = fmt.Sprintf(rpcCommitConfirmLog, confirm, buf.String())
+	}
+
+	data, err := execRPC(session, synchronized(session, rpc))
+	if err != nil {
+		return nil, err
+	}
+
+	err = commitResultsError(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return routingEngineResults(data)
+}
+
+// commitAt schedules a commit of the candidate configuration with the given
+// comment at the given time, which must be in a format accepted by Junos, ie
+// "hh:mm[:ss]" or "yyyy-mm-dd hh:mm[:ss]".
+func commitAt(session *junos.Junos, at, comment string) error {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(comment))
+	if err != nil {
+		return err
+	}
+
+	data, err := execRPC(session, synchronized(session, fmt.Sprintf(rpcCommitAtLog, at, buf.String())))
+	if err != nil {
+		return err
+	}
+
+	return commitResultsError(data)
+}
+
+// commitResultsError returns the first error found in the commit-results of
+// a commit reply.
+func commitResultsError(data string) error {
+	if !strings.Contains(data, "<commit-results") {
+		return nil
+	}
+
+	var results commitResults
+	err := xml.Unmarshal([]byte(strings.ReplaceAll(data, "\n", "")), &results)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range results.Errors {
+		if e.Path != "" {
+			return fmt.Errorf("[%s] %s: %s", strings.TrimSpace(e.Path), strings.TrimSpace(e.Element), strings.TrimSpace(e.Message))
+		}
+		return errors.New(strings.TrimSpace(e.Message))
+	}
+
+	return nil
+}
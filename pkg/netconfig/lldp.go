@@ -0,0 +1,279 @@
+package netconfig
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Status of a link checked against the LLDP neighbors of a host.
+const (
+	LinkOK         = "ok"
+	LinkMissing    = "missing"
+	LinkMiscabled  = "miscabled"
+	LinkUnexpected = "unexpected"
+)
+
+// LinkCheck is the result of comparing an interface of a host with its LLDP
+// neighbors.  Expected and Neighbor are ends of the form "host:interface".
+type LinkCheck struct {
+	Host      string `json:"host"`
+	Interface string `json:"interface"`
+	Status    string `json:"status"`
+	Expected  string `json:"expected,omitempty"`
+	Neighbor  string `json:"neighbor,omitempty"`
+}
+
+type lldpNeighbors struct {
+	XMLName   xml.Name       `xml:"lldp-neighbors-information"`
+	Neighbors []lldpNeighbor `xml:"lldp-neighbor-information"`
+}
+
+type lldpNeighbor struct {
+	LocalPortID           string `xml:"lldp-local-port-id"`
+	LocalInterface        string `xml:"lldp-local-interface"`
+	LocalParent           string `xml:"lldp-local-parent-interface-name"`
+	RemotePortID          string `xml:"lldp-remote-port-id"`
+	RemotePortDescription string `xml:"lldp-remote-port-description"`
+	RemoteSystemName      string `xml:"lldp-remote-system-name"`
+}
+
+// local returns the local interface on which the neighbor was seen.  Older
+// releases report it as the local interface rather than the local port.
+func (l lldpNeighbor) local() string {
+	if l.LocalPortID != "" {
+		return strings.TrimSpace(l.LocalPortID)
+	}
+
+	return strings.TrimSpace(l.LocalInterface)
+}
+
+// remotePort returns the interface of the neighbor, using the port
+// description when the port ID is an ifIndex.
+func (l lldpNeighbor) remotePort() string {
+	id := strings.TrimSpace(l.RemotePortID)
+	if strings.Trim(id, "0123456789") == "" && l.RemotePortDescription != "" {
+		return strings.TrimSpace(l.RemotePortDescription)
+	}
+
+	return id
+}
+
+func (l lldpNeighbor) String() string {
+	return strings.TrimSpace(l.RemoteSystemName) + ":" + l.remotePort()
+}
+
+// parseLLDPNeighbors parses the xml output of "show lldp neighbors".
+func parseLLDPNeighbors(output string) ([]lldpNeighbor, error) {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return nil, nil
+	}
+
+	var info lldpNeighbors
+	err := xml.Unmarshal([]byte(output), &info)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse lldp neighbors")
+	}
+
+	return info.Neighbors, nil
+}
+
+// expectedLink is an interface of a host and the end of the link it is
+// expected to be connected to.
+type expectedLink struct {
+	iface string
+	peer  string
+}
+
+// shortName returns the host name without its domain.
+func shortName(name string) string {
+	return strings.ToLower(strings.SplitN(strings.TrimSpace(name), ".", 2)[0])
+}
+
+// VerifyTopology collects the LLDP neighbors of the selected hosts and
+// compares them with the links of the topology and the interface descriptions
+// of the form "host:interface".  Links without a neighbor are missing, and
+// those with a neighbor other than the expected end are miscabled.  Neighbors
+// which are hosts of the inventory or topology, seen on an interface without
+// an expected link, are unexpected.
+func (n *NetConfig) VerifyTopology() ([]LinkCheck, error) {
+	known := n.topologyHostNames()
+
+	var (
+		mtx    sync.Mutex
+		checks []LinkCheck
+	)
+
+	err := n.forEachHost("verify topology of", func(h Host) error {
+		if h.NetworkHost.Platform != "junos" {
+			return nil
+		}
+
+		neighbors, err := n.lldpNeighbors(h)
+		if err != nil {
+			return err
+		}
+
+		hostChecks := verifyHostLinks(h.HostName, n.expectedLinks(h, known), neighbors, known)
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		checks = append(checks, hostChecks...)
+
+		return nil
+	})
+
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].Host != checks[j].Host {
+			return checks[i].Host < checks[j].Host
+		}
+		return checks[i].Interface < checks[j].Interface
+	})
+
+	return checks, err
+}
+
+func (n *NetConfig) lldpNeighbors(host Host) ([]lldpNeighbor, error) {
+	session, err := n.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	output, err := session.Command("show lldp neighbors", "xml")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLLDPNeighbors(output)
+}
+
+// topologyHostNames returns the short names of the selected hosts and of the
+// hosts at the ends of the links.
+func (n *NetConfig) topologyHostNames() map[string]bool {
+	known := map[string]bool{}
+
+	for _, h := range n.Hosts {
+		known[shortName(h.HostName)] = true
+	}
+
+	for _, l := range n.Data.Links {
+		for _, end := range []string{l.A, l.B} {
+			if name, _, err := splitLinkEnd(end); err == nil {
+				known[shortName(name)] = true
+			}
+		}
+	}
+
+	return known
+}
+
+// expectedLinks returns the links of the host in the topology, followed by
+// those named by the descriptions of its other interfaces.
+func (n *NetConfig) expectedLinks(host Host, known map[string]bool) []expectedLink {
+	var expected []expectedLink
+
+	seen := map[string]bool{}
+	add := func(iface, peer string) {
+		if seen[iface] {
+			return
+		}
+		seen[iface] = true
+		expected = append(expected, expectedLink{iface: iface, peer: peer})
+	}
+
+	name := shortName(host.HostName)
+
+	for _, l := range n.Data.Links {
+		ends := [2]string{l.A, l.B}
+		for i, end := range ends {
+			h, iface, err := splitLinkEnd(end)
+			if err == nil && shortName(h) == name {
+				add(iface, ends[1-i])
+			}
+		}
+	}
+
+	described := func(iface, description string) {
+		if strings.ContainsAny(description, " \t") {
+			return
+		}
+
+		h, _, err := splitLinkEnd(description)
+		if err == nil && known[shortName(h)] {
+			add(iface, description)
+		}
+	}
+
+	for _, e := range host.Data.EthernetInterfaces {
+		described(e.Name, e.Description)
+	}
+
+	for _, e := range host.Data.AEInterfaces {
+		described(e.Name, e.Description)
+	}
+
+	return expected
+}
+
+// aggregated returns true for the name of an aggregated ethernet interface.
+func aggregated(iface string) bool {
+	return strings.HasPrefix(iface, "ae")
+}
+
+// verifyHostLinks compares the expected links of a host with its neighbors.
+// The neighbors of an aggregated ethernet interface are those of its members,
+// and only their host is compared.
+func verifyHostLinks(host string, expected []expectedLink, neighbors []lldpNeighbor, known map[string]bool) []LinkCheck {
+	var checks []LinkCheck
+
+	used := make([]bool, len(neighbors))
+
+	for _, e := range expected {
+		peerHost, peerIface, _ := splitLinkEnd(e.peer)
+
+		check := LinkCheck{Host: host, Interface: e.iface, Expected: e.peer, Status: LinkMissing}
+
+		for i, nb := range neighbors {
+			if nb.local() != e.iface && strings.TrimSpace(nb.LocalParent) != e.iface {
+				continue
+			}
+			used[i] = true
+
+			matched := shortName(nb.RemoteSystemName) == shortName(peerHost) &&
+				(aggregated(e.iface) || aggregated(peerIface) || nb.remotePort() == peerIface ||
+					strings.TrimSpace(nb.RemotePortDescription) == peerIface)
+
+			switch {
+			case !matched:
+				check.Status = LinkMiscabled
+				check.Neighbor = nb.String()
+			case check.Status == LinkMissing:
+				check.Status = LinkOK
+				check.Neighbor = nb.String()
+			}
+		}
+
+		checks = append(checks, check)
+	}
+
+	for i, nb := range neighbors {
+		if used[i] || !known[shortName(nb.RemoteSystemName)] {
+			continue
+		}
+
+		checks = append(checks, LinkCheck{
+			Host:      host,
+			Interface: nb.local(),
+			Status:    LinkUnexpected,
+			Neighbor:  nb.String(),
+		})
+	}
+
+	return checks
+}
@@ -0,0 +1,131 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/scottdware/go-junos"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/junostest"
+)
+
+const testRouter1LLDP = `<lldp-neighbors-information junos:style="brief">
+<lldp-neighbor-information>
+<lldp-local-port-id>ge-0/0/0</lldp-local-port-id>
+<lldp-remote-port-id>ge-0/0/0</lldp-remote-port-id>
+<lldp-remote-system-name>router2.example.com</lldp-remote-system-name>
+</lldp-neighbor-information>
+<lldp-neighbor-information>
+<lldp-local-port-id>ge-0/0/1</lldp-local-port-id>
+<lldp-remote-port-id>ge-0/0/2</lldp-remote-port-id>
+<lldp-remote-system-name>router2</lldp-remote-system-name>
+</lldp-neighbor-information>
+<lldp-neighbor-information>
+<lldp-local-interface>xe-0/1/0</lldp-local-interface>
+<lldp-local-parent-interface-name>ae0</lldp-local-parent-interface-name>
+<lldp-remote-port-id>530</lldp-remote-port-id>
+<lldp-remote-port-description>xe-0/1/0</lldp-remote-port-description>
+<lldp-remote-system-name>router2</lldp-remote-system-name>
+</lldp-neighbor-information>
+<lldp-neighbor-information>
+<lldp-local-port-id>ge-0/0/7</lldp-local-port-id>
+<lldp-remote-port-id>00:11:22:33:44:55</lldp-remote-port-id>
+<lldp-remote-system-name>phone</lldp-remote-system-name>
+</lldp-neighbor-information>
+</lldp-neighbors-information>`
+
+const testRouter2LLDP = `<lldp-neighbors-information>
+<lldp-neighbor-information>
+<lldp-local-port-id>ge-0/0/0</lldp-local-port-id>
+<lldp-remote-port-id>ge-0/0/0</lldp-remote-port-id>
+<lldp-remote-system-name>router1</lldp-remote-system-name>
+</lldp-neighbor-information>
+<lldp-neighbor-information>
+<lldp-local-port-id>ge-0/0/2</lldp-local-port-id>
+<lldp-remote-port-id>ge-0/0/1</lldp-remote-port-id>
+<lldp-remote-system-name>router1</lldp-remote-system-name>
+</lldp-neighbor-information>
+<lldp-neighbor-information>
+<lldp-local-port-id>xe-0/1/0</lldp-local-port-id>
+<lldp-local-parent-interface-name>ae0</lldp-local-parent-interface-name>
+<lldp-remote-port-id>xe-0/1/0</lldp-remote-port-id>
+<lldp-remote-system-name>router1</lldp-remote-system-name>
+</lldp-neighbor-information>
+</lldp-neighbors-information>`
+
+func TestVerifyTopology(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+hierarchy:
+  - "host/{{ .NetworkHost.Name }}.yaml"
+`,
+		"data/host/router2.yaml": `
+eth_interfaces:
+  - name: ge-0/0/9
+    description: router1:ge-0/0/9
+  - name: ge-0/0/10
+    description: printer:port1
+`,
+		"topology.yaml": `
+links:
+  - a: router1:ge-0/0/0
+    b: router2:ge-0/0/0
+    subnet: 10.255.0.0/31
+  - a: router1:ge-0/0/1
+    b: router2:ge-0/0/1
+    subnet: 10.255.0.2/31
+  - a: router1:ae0
+    b: router2:ae0
+    vlans: [users]
+  - a: router1:ge-0/0/3
+    b: router3:ge-0/0/0
+`,
+	})
+
+	devices := map[string]*junostest.Server{}
+	for name, lldp := range map[string]string{"router1": testRouter1LLDP, "router2": testRouter2LLDP} {
+		device, err := junostest.NewServer(name, testRunningConfig)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = device.Close() })
+
+		device.SetCommand("show lldp neighbors", lldp)
+		devices[name] = device
+	}
+
+	n, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}}, []inventory.NetworkHost{
+		{Name: "router1", Domain: "example.com", Platform: "junos"},
+		{Name: "router2", Domain: "example.com", Platform: "junos"},
+		{Name: "router3", Domain: "example.com", Platform: "eos"},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	n.SetSessionDialer(func(host Host) (*junos.Junos, error) {
+		return devices[host.NetworkHost.Name].Dial()
+	})
+
+	checks, err := n.VerifyTopology()
+	require.NoError(t, err)
+
+	require.Equal(t, []LinkCheck{
+		{Host: "router1.example.com", Interface: "ae0", Status: LinkOK, Expected: "router2:ae0", Neighbor: "router2:xe-0/1/0"},
+		{Host: "router1.example.com", Interface: "ge-0/0/0", Status: LinkOK, Expected: "router2:ge-0/0/0", Neighbor: "router2.example.com:ge-0/0/0"},
+		{Host: "router1.example.com", Interface: "ge-0/0/1", Status: LinkMiscabled, Expected: "router2:ge-0/0/1", Neighbor: "router2:ge-0/0/2"},
+		{Host: "router1.example.com", Interface: "ge-0/0/3", Status: LinkMissing, Expected: "router3:ge-0/0/0"},
+		{Host: "router2.example.com", Interface: "ae0", Status: LinkOK, Expected: "router1:ae0", Neighbor: "router1:xe-0/1/0"},
+		{Host: "router2.example.com", Interface: "ge-0/0/0", Status: LinkOK, Expected: "router1:ge-0/0/0", Neighbor: "router1:ge-0/0/0"},
+		{Host: "router2.example.com", Interface: "ge-0/0/1", Status: LinkMissing, Expected: "router1:ge-0/0/1"},
+		{Host: "router2.example.com", Interface: "ge-0/0/2", Status: LinkUnexpected, Neighbor: "router1:ge-0/0/1"},
+		{Host: "router2.example.com", Interface: "ge-0/0/9", Status: LinkMissing, Expected: "router1:ge-0/0/9"},
+	}, checks)
+}
+
+func TestParseLLDPNeighbors(t *testing.T) {
+	neighbors, err := parseLLDPNeighbors("\n")
+	require.NoError(t, err)
+	require.Empty(t, neighbors)
+
+	_, err = parseLLDPNeighbors("<lldp-neighbors-information>")
+	require.Error(t, err)
+}
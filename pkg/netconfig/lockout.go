@@ -0,0 +1,166 @@
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/scottdware/go-junos"
+)
+
+// The handling of changes which affect management access to a device.
+const (
+	// LockoutConfirm commits such changes with commit confirmed, confirming
+	// them only once a new session to the device can be opened.
+	LockoutConfirm = "confirm"
+	// LockoutRefuse refuses to commit such changes.
+	LockoutRefuse = "refuse"
+	// LockoutOff disables the protection.
+	LockoutOff = "off"
+)
+
+// lockoutRisks returns a description of each change in the diff which may
+// remove the access netconfig has to the device: the interface through which
+// it connects, the login user, the SSH service and the loopback firewall
+// filters.
+func (n *NetConfig) lockoutRisks(session *junos.Junos, host Host, s diffSummary) ([]string, error) {
+	if n.cfg.Lockout == LockoutOff {
+		return nil, nil
+	}
+
+	changed := s.changed()
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	text, err := getConfigText(session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the configuration of %s: %w", host.HostName, err)
+	}
+
+	running, err := parseStatements(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the configuration of %s: %w", host.HostName, err)
+	}
+
+	protected := [][]string{
+		{"system", "services", "ssh"},
+		{"interfaces", "lo0", "unit", "*", "family", "*", "filter"},
+	}
+
+	if n.cfg.Junos.Username != "" {
+		protected = append(protected, []string{"system", "login", "user", n.cfg.Junos.Username})
+	}
+
+	for _, ifd := range managementInterfaces(running, managementAddresses(host)) {
+		protected = append(protected, []string{"interfaces", ifd})
+	}
+
+	for _, filter := range loopbackFilters(append(running, s.addedStatements...)) {
+		protected = append(protected,
+			[]string{"firewall", "filter", filter},
+			[]string{"firewall", "family", "*", "filter", filter},
+		)
+	}
+
+	var risks []string
+	for _, p := range protected {
+		for _, c := range changed {
+			if c.hasPrefix(p) {
+				risks = append(risks, fmt.Sprintf("changes %q", strings.Join(p, " ")))
+				break
+			}
+		}
+	}
+
+	return risks, nil
+}
+
+// managementAddresses returns the addresses through which netconfig may be
+// connected to the host.
+func managementAddresses(host Host) []string {
+	var addrs []string
+
+	if host.NetworkHost != nil {
+		addrs = append(addrs, host.NetworkHost.InetAddress...)
+		addrs = append(addrs, host.NetworkHost.Inet6Address...)
+	}
+
+	resolved, err := net.LookupHost(host.HostName)
+	if err == nil {
+		addrs = append(addrs, resolved...)
+	}
+
+	return addrs
+}
+
+// managementInterfaces returns the interfaces which hold one of the
+// addresses in the configuration.
+func managementInterfaces(statements []statement, addrs []string) []string {
+	var interfaces []string
+
+	for _, s := range statements {
+		if len(s) < 2 || s[0] != "interfaces" {
+			continue
+		}
+
+		for i := 2; i < len(s)-1; i++ {
+			if s[i] != "address" {
+				continue
+			}
+
+			ip := strings.SplitN(s[i+1], "/", 2)[0]
+			for _, a := range addrs {
+				if net.ParseIP(ip) != nil && net.ParseIP(ip).Equal(net.ParseIP(a)) && !containsString(interfaces, s[1]) {
+					interfaces = append(interfaces, s[1])
+				}
+			}
+		}
+	}
+
+	return interfaces
+}
+
+// loopbackFilters returns the names of the firewall filters applied to the
+// loopback interface.
+func loopbackFilters(statements []statement) []string {
+	var filters []string
+
+	pattern := []string{"interfaces", "lo0", "unit", "*", "family", "*", "filter"}
+	for _, s := range statements {
+		if !s.hasPrefix(pattern) || len(s) < len(pattern)+2 {
+			continue
+		}
+
+		for _, name := range strings.Fields(strings.Trim(s[len(pattern)+1], "[]")) {
+			if !containsString(filters, name) {
+				filters = append(filters, name)
+			}
+		}
+	}
+
+	return filters
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyAccess opens a new session to the host, to show that a change
+// committed with commit confirmed has not removed the access of netconfig.
+func (n *NetConfig) verifyAccess(host Host) error {
+	session, err := n.dial(host)
+	if err != nil {
+		return err
+	}
+
+	session.Close()
+
+	return nil
+}
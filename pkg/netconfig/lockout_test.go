@@ -0,0 +1,131 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xaque208/netconfig/pkg/junostest"
+)
+
+const testLockoutConfig = `
+system {
+    host-name router1;
+    services {
+        ssh;
+    }
+}
+interfaces {
+    ge-0/0/0 {
+        unit 0 {
+            family inet {
+                address 192.0.2.10/24;
+            }
+        }
+    }
+    ge-0/0/1 {
+        unit 0 {
+            family inet6 {
+                address 2001:db8::1/64;
+            }
+        }
+    }
+    lo0 {
+        unit 0 {
+            family inet {
+                filter {
+                    input PROTECT-RE;
+                }
+            }
+            family inet6 {
+                filter {
+                    input-list [ PROTECT-RE6 ACCEPT-ALL ];
+                }
+            }
+        }
+    }
+}
+`
+
+func TestManagementInterfaces(t *testing.T) {
+	statements, err := parseStatements(testLockoutConfig)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"ge-0/0/0"}, managementInterfaces(statements, []string{"192.0.2.10"}))
+	require.Equal(t, []string{"ge-0/0/1"}, managementInterfaces(statements, []string{"2001:db8:0::1"}))
+	require.Empty(t, managementInterfaces(statements, []string{"192.0.2.11"}))
+}
+
+func TestLoopbackFilters(t *testing.T) {
+	statements, err := parseStatements(testLockoutConfig)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"PROTECT-RE", "PROTECT-RE6", "ACCEPT-ALL"}, loopbackFilters(statements))
+}
+
+func TestConfigureNetworkLockout(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["templates/platform/junos/ssh.tmpl"] = `
+system {
+    services {
+        ssh {
+            root-login deny;
+        }
+    }
+}
+`
+	dataCfg := DataConfig{Directory: writeTestData(t, files)}
+
+	newDevice := func() *junostest.Server {
+		device, err := junostest.NewServer("router1", testLockoutConfig)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = device.Close() })
+
+		return device
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		device := newDevice()
+		n := newTestNetConfig(t, Config{Commit: true, Data: dataCfg, Lockout: LockoutConfirm}, device)
+
+		require.NoError(t, n.ConfigureNetwork())
+		require.Contains(t, device.Running(), "root-login deny;")
+		require.False(t, device.ConfirmPending())
+
+		commits := device.Commits()
+		require.Len(t, commits, 2)
+		require.False(t, commits[0].Confirmed)
+		require.True(t, commits[1].Confirmed)
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		device := newDevice()
+		n := newTestNetConfig(t, Config{Commit: true, Data: dataCfg, Lockout: LockoutRefuse}, device)
+
+		require.Error(t, n.ConfigureNetwork())
+		require.Empty(t, device.Commits())
+		require.Equal(t, device.Running(), device.Candidate())
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		device := newDevice()
+		n := newTestNetConfig(t, Config{Commit: true, Data: dataCfg, CommitAt: "02:00"}, device)
+
+		require.Error(t, n.ConfigureNetwork())
+		require.Empty(t, device.Scheduled())
+	})
+
+	t.Run("off", func(t *testing.T) {
+		device := newDevice()
+		n := newTestNetConfig(t, Config{Commit: true, Data: dataCfg, Lockout: LockoutOff}, device)
+
+		require.NoError(t, n.ConfigureNetwork())
+
+		commits := device.Commits()
+		require.Len(t, commits, 1)
+		require.False(t, commits[0].Confirmed)
+	})
+}
@@ -0,0 +1,90 @@
+package netconfig
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The phases of a push in which a host may fail, used to label
+// metricFailures.
+const (
+	phaseRender     = "render"
+	phaseAssert     = "assert"
+	phaseSession    = "session"
+	phasePreflight  = "preflight"
+	phaseLock       = "lock"
+	phaseLoad       = "load"
+	phaseDiff       = "diff"
+	phaseGuardrails = "guardrails"
+	phaseLockout    = "lockout"
+	phaseCommit     = "commit"
+	phaseVerify     = "verify"
+)
+
+// Registry holds the metrics of the runs.  It is kept apart from the default
+// registry so that the metrics exported by one-shot runs do not include the Go
+// runtime and process metrics of the command.
+var Registry = prometheus.NewRegistry()
+
+var (
+	metricRuns = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Name: "netconfig_runs_total",
+		Help: "The number of runs which configured the network.",
+	})
+
+	metricRunTimestamp = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Name: "netconfig_last_run_timestamp_seconds",
+		Help: "The time at which the last run finished.",
+	})
+
+	metricRunDuration = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Name: "netconfig_last_run_duration_seconds",
+		Help: "The duration of the last run.",
+	})
+
+	metricHostsSelected = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Name: "netconfig_hosts_selected",
+		Help: "The number of hosts selected by the last run.",
+	})
+
+	metricHostsSkipped = promauto.With(Registry).NewGauge(prometheus.GaugeOpts{
+		Name: "netconfig_hosts_skipped",
+		Help: "The number of hosts skipped by the last run as their rendered configuration was unchanged.",
+	})
+
+	metricRenderDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "netconfig_render_duration_seconds",
+		Help:    "The time taken to render the configuration of a host.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"host"})
+
+	metricSessionDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "netconfig_session_duration_seconds",
+		Help:    "The time for which a session to a host was open.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"host"})
+
+	metricDiffLines = promauto.With(Registry).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "netconfig_diff_lines",
+		Help: "The number of lines added and removed by the last diff of a host.",
+	}, []string{"host", "change"})
+
+	metricCommits = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "netconfig_commits_total",
+		Help: "The number of commits made to a host.",
+	}, []string{"host"})
+
+	metricFailures = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Name: "netconfig_failures_total",
+		Help: "The number of failures of a host, by the phase of the push in which it failed.",
+	}, []string{"host", "phase"})
+)
+
+// recordFailure records a failure of the host in the given phase, returning err.
+func recordFailure(host Host, phase string, err error) error {
+	if err != nil {
+		metricFailures.WithLabelValues(host.HostName, phase).Inc()
+	}
+
+	return err
+}
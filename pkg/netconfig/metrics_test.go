@@ -0,0 +1,55 @@
+package netconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureNetworkMetrics(t *testing.T) {
+	const host = "router1.example.com"
+
+	commits := testutil.ToFloat64(metricCommits.WithLabelValues(host))
+	failures := testutil.ToFloat64(metricFailures.WithLabelValues(host, phaseGuardrails))
+
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Guardrails: GuardrailConfig{MaxAdded: 1}}, device)
+
+	// The diff of one added server is within the guardrails.
+	require.NoError(t, n.ConfigureNetwork())
+	require.Equal(t, commits+1, testutil.ToFloat64(metricCommits.WithLabelValues(host)))
+	require.Equal(t, float64(1), testutil.ToFloat64(metricDiffLines.WithLabelValues(host, "added")))
+	require.Equal(t, float64(1), testutil.ToFloat64(metricHostsSelected))
+
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data/host/router1.yaml"] = `
+ntp_servers:
+  - 10.0.0.3
+  - 10.0.0.4
+`
+
+	n = newTestNetConfig(t, Config{Commit: true, Data: DataConfig{Directory: writeTestData(t, files)}, Guardrails: GuardrailConfig{MaxAdded: 1}}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+	require.Equal(t, commits+1, testutil.ToFloat64(metricCommits.WithLabelValues(host)))
+	require.Equal(t, failures+1, testutil.ToFloat64(metricFailures.WithLabelValues(host, phaseGuardrails)))
+}
+
+func TestRegistry(t *testing.T) {
+	families, err := Registry.Gather()
+	require.NoError(t, err)
+
+	// The registry holds the metrics of the runs alone, without the Go
+	// runtime and process metrics of the default registry.
+	var names []string
+	for _, f := range families {
+		require.True(t, strings.HasPrefix(f.GetName(), "netconfig_"), f.GetName())
+		names = append(names, f.GetName())
+	}
+	require.Contains(t, names, "netconfig_runs_total")
+}
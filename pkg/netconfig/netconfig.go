@@ -4,18 +4,17 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
-	"text/template"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/imdario/mergo"
 	"github.com/pkg/errors"
 	"github.com/scottdware/go-junos"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/xaque208/znet/modules/inventory"
@@ -36,69 +35,209 @@ type NetConfig struct {
 	logger log.Logger
 	cfg    *Config
 
-	junosAuth *junos.AuthMethod
-	ldap      *inventory.LDAPInventory
+	dial       SessionDialer
+	inv        inventory.Inventory
+	provenance Provenance
+
+	approver   Approver
+	approveMtx sync.Mutex
+	aborted    int32
+
+	guardMtx   sync.Mutex
+	runAdded   int
+	runRemoved int
+
+	progressFunc func(Progress)
+	reportMtx    sync.Mutex
+	report       RunReport
+	results      map[string]*HostResult
+
+	stateMtx sync.Mutex
+
+	cache        *fileCache
+	allocator    *allocator
+	allocateOnce sync.Once
+	allocateErr  error
 
 	Data  data.Data
 	Hosts []Host
 }
 
-// New is used to build a new *NetConfig.
+// SessionDialer opens a Junos session to a host.
+type SessionDialer func(host Host) (*junos.Junos, error)
+
+// New is used to build a new *NetConfig, using LDAP for the inventory.
 func New(cfg Config, logger log.Logger) (*NetConfig, error) {
+	inv, err := inventory.NewLDAPInventory(cfg.Inventory, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWithInventory(cfg, inv, logger)
+}
+
+// NewWithInventory is used to build a new *NetConfig from the hosts of the
+// given inventory.
+func NewWithInventory(cfg Config, inv inventory.Inventory, logger log.Logger) (*NetConfig, error) {
+	ctx, span := tracer().Start(context.Background(), "ListNetworkHosts")
+	hosts, err := inv.ListNetworkHosts(ctx)
+	span.SetAttributes(attrHostCount.Int(len(hosts)))
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := NewWithHosts(cfg, hosts, logger)
+	if err != nil {
+		return nil, err
+	}
+	n.inv = inv
+
+	if len(n.Data.InventoryNetworks) == 0 {
+		return n, nil
+	}
+
+	// The data derived from the networks of the inventory is added to the
+	// data loaded from the hierarchy.
+	ctx, span = tracer().Start(context.Background(), "ListNetworks")
+	networks, err := listL3Networks(ctx, inv)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range n.Hosts {
+		err = n.applyInventoryNetworks(&n.Hosts[i], networks)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to derive network data for %s", n.Hosts[i].HostName)
+		}
+	}
+
+	return n, nil
+}
+
+// NewWithHosts is used to build a new *NetConfig from the given network
+// hosts.
+func NewWithHosts(cfg Config, hosts []inventory.NetworkHost, logger log.Logger) (*NetConfig, error) {
+	return newWithHosts(cfg, hosts, newFileCache(), logger)
+}
+
+// newWithHosts builds a new *NetConfig which reads the data directory through
+// the cache.
+func newWithHosts(cfg Config, hosts []inventory.NetworkHost, cache *fileCache, logger log.Logger) (*NetConfig, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	logger = log.With(logger, "module", "timer")
 	n := &NetConfig{
 		logger: logger,
 		cfg:    &cfg,
-		junosAuth: &junos.AuthMethod{
-			Username:   cfg.Junos.Username,
-			PrivateKey: cfg.Junos.Keyfile,
+		cache:  cache,
+		provenance: Provenance{
+			RunID:    newRunID(),
+			Operator: strings.Join(strings.Fields(cfg.Operator), "_"),
+			Revision: gitRevision(cfg.Data.Directory),
 		},
 	}
 
-	data, err := loadData(cfg.Data.Directory, logger)
-	if err != nil {
-		return nil, err
+	auth := &junos.AuthMethod{
+		Username:   cfg.Junos.Username,
+		PrivateKey: cfg.Junos.Keyfile,
+	}
+	n.dial = func(host Host) (*junos.Junos, error) {
+		return junos.NewSession(host.HostName, auth)
 	}
-	n.Data = data
 
-	inv, err := inventory.NewLDAPInventory(cfg.Inventory, logger)
+	data, err := loadData(cfg.Data.Directory, logger)
 	if err != nil {
 		return nil, err
 	}
-	n.ldap = inv
+	n.Data = data
 
-	hosts, err := inv.ListNetworkHosts(context.TODO())
+	// Allocations are only recorded by runs which commit, so that rendering
+	// and diffing leave the data directory untouched.
+	n.allocator, err = loadAllocator(cfg.Data.Directory, data, cfg.Commit && !cfg.DryRun)
 	if err != nil {
 		return nil, err
 	}
 
 	_ = level.Debug(logger).Log("msg", "netconfig", "host_count", len(hosts))
 
+	ctx, span := tracer().Start(context.Background(), "LoadHostData")
+
 	for i := range hosts {
 		if hosts[i].Platform != "junos" {
 			continue
 		}
 
+		if !cfg.selected(&hosts[i]) {
+			continue
+		}
+
 		netHost := proto.Clone(&hosts[i])
 
-		host := Host{
+		n.Hosts = append(n.Hosts, Host{
 			NetworkHost: netHost.(*inventory.NetworkHost),
 			HostName:    strings.Join([]string{hosts[i].Name, hosts[i].Domain}, "."),
 			// Environment: env,
-		}
+		})
+	}
 
-		d, err := n.dataForHost(host)
+	// The data of the hosts is loaded concurrently, sharing the files of the
+	// hierarchy through the cache.
+	errs := make([]error, len(n.Hosts))
+
+	wg := sync.WaitGroup{}
+	for i := range n.Hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n.Hosts[i].Data, errs[i] = n.dataForHost(ctx, n.Hosts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
+			endSpan(span, err)
 			return nil, err
 		}
-		host.Data = d
+	}
+
+	// The interfaces at each end of the links of the topology are generated
+	// after the data of the hosts is loaded.
+	links, err := loadTopology(cfg.Data.Directory, data)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	n.Data.Links = append(n.Data.Links, links...)
 
-		n.Hosts = append(n.Hosts, host)
+	err = n.applyTopology(n.Data.Links, hosts)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
 	}
 
+	span.SetAttributes(attrHostCount.Int(len(n.Hosts)))
+	span.End()
+
 	return n, nil
 }
 
+// SetSessionDialer replaces the function used to open sessions to hosts.
+func (n *NetConfig) SetSessionDialer(d SessionDialer) {
+	n.dial = d
+}
+
+// Provenance returns the provenance recorded in the comment of commits made
+// during this run.
+func (n *NetConfig) Provenance() Provenance {
+	return n.provenance
+}
+
 // LoadData receives a configuration directory from which to load the data for NetConfig.
 func loadData(configDir string, logger log.Logger) (data.Data, error) {
 	_ = level.Debug(logger).Log("msg", "loading data", "path", configDir)
@@ -117,24 +256,110 @@ func (n *NetConfig) ConfigureNetwork() error {
 		return fmt.Errorf("unable to configure network with nil NetConfig")
 	}
 
+	if n.cfg.StateFile == "" {
+		return n.configureHosts(n.Hosts, nil)
+	}
+
+	return n.configureIncremental()
+}
+
+// configureHosts configures the hosts as a single run.  The unchanged hosts
+// are skipped, or only checked for drift when configured.
+func (n *NetConfig) configureHosts(hosts, unchanged []Host) (err error) {
+	ctx, span := tracer().Start(context.Background(), "ConfigureNetwork",
+		trace.WithAttributes(attrRunID.String(n.provenance.RunID), attrHostCount.Int(len(hosts)+len(unchanged))))
+	defer func() { endSpan(span, err) }()
+
+	_ = level.Info(n.logger).Log("msg", "configuring network", "run_id", n.provenance.RunID, "revision", n.provenance.Revision)
+
+	start := time.Now()
+	metricRuns.Inc()
+	metricHostsSelected.Set(float64(len(hosts) + len(unchanged)))
+	metricHostsSkipped.Set(float64(len(unchanged)))
+
+	n.startReport()
+
+	if !n.cfg.CheckDrift {
+		for _, h := range unchanged {
+			n.skipHost(h)
+		}
+		unchanged = nil
+	}
+
+	defer func() {
+		n.finishReport()
+		metricRunDuration.Set(time.Since(start).Seconds())
+		metricRunTimestamp.SetToCurrentTime()
+	}()
+
+	// The data of the hosts is checked for consistency with each other as a
+	// pre-flight check of the run, before any host is locked.
+	if n.cfg.Preflight.Enabled {
+		err = n.checkConsistency()
+		if err != nil {
+			for _, h := range hosts {
+				n.finishHost(h, recordFailure(h, phasePreflight, err))
+			}
+			return err
+		}
+	}
+
+	err = n.forHosts(hosts, "configure", func(h Host) error {
+		if h.NetworkHost.Platform != "junos" {
+			return nil
+		}
+
+		_ = level.Debug(n.logger).Log("msg", "configuring", "host", h.HostName)
+
+		return n.configureNetworkHost(ctx, h)
+	})
+
+	driftErr := n.forHosts(unchanged, "check drift of", func(h Host) error {
+		return n.checkDrift(ctx, h)
+	})
+	if err == nil {
+		err = driftErr
+	}
+
+	return err
+}
+
+// forEachHost calls fn concurrently for each of the hosts, logging any
+// failures.  The returned error names the hosts for which fn failed.
+func (n *NetConfig) forEachHost(action string, fn func(Host) error) error {
+	return n.forHosts(n.Hosts, action, fn)
+}
+
+// forHosts calls fn concurrently for each of the given hosts, as forEachHost.
+func (n *NetConfig) forHosts(hosts []Host, action string, fn func(Host) error) error {
+	var (
+		mtx    sync.Mutex
+		failed []string
+	)
+
 	wg := sync.WaitGroup{}
-	for _, host := range n.Hosts {
+	for _, host := range hosts {
 		wg.Add(1)
 		go func(h Host) {
-			if h.NetworkHost.Platform == "junos" {
-				_ = level.Debug(n.logger).Log("msg", "configuring", "host", h.HostName)
+			defer wg.Done()
 
-				err := n.ConfigureNetworkHost(h)
-				if err != nil {
-					_ = level.Error(n.logger).Log("msg", "failed to configure", "host", h.HostName, "err", err)
-				}
-			}
+			err := fn(h)
+			if err != nil {
+				_ = level.Error(n.logger).Log("msg", "failed to "+action, "host", h.HostName, "err", err)
 
-			wg.Done()
+				mtx.Lock()
+				failed = append(failed, h.HostName)
+				mtx.Unlock()
+			}
 		}(host)
 	}
 	wg.Wait()
 
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to %s %d host(s): %s", action, len(failed), strings.Join(failed, ", "))
+	}
+
 	return nil
 }
 
@@ -142,99 +367,548 @@ func (n *NetConfig) ConfigureNetwork() error {
 // network host.  The hosts about which to load the templates, are retrieved
 // from LDAP.
 func (n *NetConfig) ConfigureNetworkHost(host Host) error {
-	session, err := junos.NewSession(host.HostName, n.junosAuth)
+	return n.configureNetworkHost(context.Background(), host)
+}
+
+func (n *NetConfig) configureNetworkHost(ctx context.Context, host Host) (err error) {
+	ctx, span := tracer().Start(ctx, "ConfigureNetworkHost", hostAttributes(host))
+	defer func() {
+		endSpan(span, err)
+		n.finishHost(host, err)
+	}()
+
+	n.progress(host, phaseRender)
+
+	renderStart := time.Now()
+	rendered, err := n.renderHost(ctx, host)
+	if err != nil {
+		return recordFailure(host, phaseRender, err)
+	}
+	metricRenderDuration.WithLabelValues(host.HostName).Observe(time.Since(renderStart).Seconds())
+
+	if n.cfg.Diff {
+		_ = level.Debug(n.logger).Log("msg", "rendered templates", "output", rendered)
+	}
+
+	n.progress(host, phaseAssert)
+
+	err = n.assertHost(host, rendered)
+	if err != nil {
+		return recordFailure(host, phaseAssert, err)
+	}
+
+	session, closeSession, err := n.openCandidate(ctx, host, rendered)
 	if err != nil {
 		return err
 	}
+	defer closeSession()
 
-	defer session.Close()
+	return n.applyCandidate(ctx, session, host)
+}
 
-	templates := n.templatesForDevice(host)
+// openCandidate opens a session to the host, locks the configuration, or opens
+// a private candidate, and loads the rendered configuration into the
+// candidate.  The returned function unlocks the configuration and closes the
+// session.
+func (n *NetConfig) openCandidate(ctx context.Context, host Host, rendered string) (*junos.Junos, func(), error) {
+	session, closeSession, err := n.openSession(ctx, host)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	_ = level.Debug(n.logger).Log("msg", "templates for device", "count", len(templates))
+	unlockSession, err := n.prepareCandidate(ctx, session, host, closeSession)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	var renderedTemplates []string
-	for _, t := range templates {
-		result, renderErr := n.renderHostTemplateFile(host, t)
-		if renderErr != nil {
-			return renderErr
+	n.progress(host, phaseLoad)
+
+	_, loadSpan := tracer().Start(ctx, "LoadConfiguration", hostAttributes(host))
+	err = session.Config([]string{rendered}, "text", false)
+	endSpan(loadSpan, err)
+	if err != nil {
+		unlockSession()
+		return nil, nil, recordFailure(host, phaseLoad, fmt.Errorf("unable to load configuration on %s: %s", host.HostName, err))
+	}
+
+	return session, unlockSession, nil
+}
+
+// openSession opens a session to the host, returning the function which
+// closes it.
+func (n *NetConfig) openSession(ctx context.Context, host Host) (*junos.Junos, func(), error) {
+	n.progress(host, phaseSession)
+
+	sessionStart := time.Now()
+	_, sessionSpan := tracer().Start(ctx, "OpenSession", hostAttributes(host))
+	session, err := n.dial(host)
+	endSpan(sessionSpan, err)
+	if err != nil {
+		return nil, nil, recordFailure(host, phaseSession, err)
+	}
+
+	closeSession := func() {
+		session.Close()
+		metricSessionDuration.WithLabelValues(host.HostName).Observe(time.Since(sessionStart).Seconds())
+	}
+
+	return session, closeSession, nil
+}
+
+// prepareCandidate checks the health of the host and locks its candidate, as
+// lockCandidate, before a configuration is loaded into it.  The returned
+// function unlocks the candidate and closes the session, which is closed at
+// once when the host is refused.
+func (n *NetConfig) prepareCandidate(ctx context.Context, session *junos.Junos, host Host, closeSession func()) (func(), error) {
+	// Unhealthy hosts are not locked, so that they are not half configured.
+	if n.cfg.Preflight.Enabled {
+		n.progress(host, phasePreflight)
+
+		failures, err := n.preflight(session)
+		if err == nil && len(failures) > 0 {
+			n.updateResult(host, func(r *HostResult) { r.Preflight = failures })
+			err = fmt.Errorf("pre-flight checks failed on %s: %s", host.HostName, strings.Join(failures, "; "))
+		}
+		if err != nil {
+			closeSession()
+			return nil, recordFailure(host, phasePreflight, err)
 		}
-		renderedTemplates = append(renderedTemplates, result)
 	}
 
-	if n.cfg.Diff {
-		_ = level.Debug(n.logger).Log("msg", "rendered templates", "output", renderedTemplates)
+	n.progress(host, phaseLock)
+
+	// A session to a backup routing engine is refused before it is locked.
+	err := n.checkMaster(session, host)
+	if err != nil {
+		closeSession()
+		return nil, recordFailure(host, phaseLock, err)
 	}
 
-	err = session.Lock()
+	_, lockSpan := tracer().Start(ctx, "Lock", hostAttributes(host))
+	unlock, err := n.lockCandidate(session, host)
+	endSpan(lockSpan, err)
 	if err != nil {
-		return errors.Wrap(err, "unable to lock session on host "+host.HostName)
+		closeSession()
+		return nil, recordFailure(host, phaseLock, err)
 	}
 
-	defer func() {
-		err = session.Unlock()
+	return func() {
+		_, unlockSpan := tracer().Start(ctx, "Unlock", hostAttributes(host))
+		unlockErr := unlock()
+		endSpan(unlockSpan, unlockErr)
+		if unlockErr != nil {
+			_ = level.Error(n.logger).Log("msg", "error unlocking session", "host", host.HostName, "err", unlockErr)
+		}
+
+		closeSession()
+	}, nil
+}
+
+// lockCandidate locks the configuration of the host, or opens a private
+// candidate when configured, returning the function which releases it.  A host
+// whose candidate has the uncommitted changes of other users is refused, and
+// the changes reported, rather than discarded.
+func (n *NetConfig) lockCandidate(session *junos.Junos, host Host) (func() error, error) {
+	uncommitted, err := uncommittedChanges(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if uncommitted != "" {
+		n.updateResult(host, func(r *HostResult) { r.Uncommitted = uncommitted })
+		return nil, fmt.Errorf("refusing to configure %s, its candidate has uncommitted changes made by another user", host.HostName)
+	}
+
+	if n.cfg.Private {
+		err = openPrivate(session)
 		if err != nil {
-			_ = level.Error(n.logger).Log("msg", "error unlocking session", "host", host.HostName, "err", err)
+			return nil, errors.Wrap(err, "unable to open a private candidate on host "+host.HostName)
 		}
-	}()
 
-	err = session.Config(renderedTemplates, "text", false)
+		return func() error { return closePrivate(session) }, nil
+	}
+
+	err = session.Lock()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lock session on host "+host.HostName)
+	}
+
+	return session.Unlock, nil
+}
+
+// DiffHost renders the configuration of the host and loads it into the
+// candidate, returning the difference from the active configuration.  The
+// candidate is always discarded.
+func (n *NetConfig) DiffHost(host Host) (_ string, err error) {
+	ctx, span := tracer().Start(context.Background(), "DiffHost", hostAttributes(host))
+	defer func() { endSpan(span, err) }()
+
+	rendered, err := n.renderHost(ctx, host)
 	if err != nil {
-		return fmt.Errorf("unable to load configuration on %s: %s", host.HostName, err)
+		return "", err
 	}
 
+	session, closeSession, err := n.openCandidate(ctx, host, rendered)
+	if err != nil {
+		return "", err
+	}
+	defer closeSession()
+
+	diff, err := session.Diff(0)
+	if err != nil {
+		return "", recordFailure(host, phaseDiff, err)
+	}
+
+	err = discardCandidate(session)
+	if err != nil {
+		return "", err
+	}
+
+	if len(diff) <= 1 {
+		return "", nil
+	}
+
+	return diff, nil
+}
+
+// applyCandidate shows the difference between the candidate and the active
+// configuration on the host, and then either commits or discards the
+// candidate.
+func (n *NetConfig) applyCandidate(ctx context.Context, session *junos.Junos, host Host) error {
+	n.progress(host, phaseDiff)
+
+	_, diffSpan := tracer().Start(ctx, "Diff", hostAttributes(host))
 	diffResult, err := session.Diff(0)
 	if err != nil {
-		return err
+		endSpan(diffSpan, err)
+		return recordFailure(host, phaseDiff, err)
 	}
 
-	if len(diffResult) > 1 {
-		_ = level.Info(n.logger).Log("msg", "configuration changes", "host", host.HostName)
-		fmt.Printf("%+v", diffResult)
+	summary := summarizeDiff(diffResult)
+	diffSpan.SetAttributes(attrDiffAdded.Int(summary.added), attrDiffRemoved.Int(summary.removed))
+	diffSpan.End()
 
-		if n.cfg.Commit {
-			if n.cfg.CommitConfirmed > 0 {
-				err = session.CommitConfirm(n.cfg.CommitConfirmed)
-				if err != nil {
-					return err
-				}
-			} else {
-				err = session.Commit()
-				if err != nil {
-					return err
-				}
+	n.updateResult(host, func(r *HostResult) {
+		r.Diff = diffResult
+		r.Changed = len(diffResult) > 1
+	})
+
+	metricDiffLines.WithLabelValues(host.HostName, "added").Set(float64(summary.added))
+	metricDiffLines.WithLabelValues(host.HostName, "removed").Set(float64(summary.removed))
+
+	if len(diffResult) <= 1 {
+		return nil
+	}
+
+	_ = level.Info(n.logger).Log("msg", "configuration changes", "host", host.HostName)
+
+	commitNow := n.cfg.Commit
+	confirm := n.cfg.CommitConfirmed
+
+	// The guardrails are only checked when the diff may be committed.
+	guarded := n.cfg.Commit || n.approver != nil
+	if guarded {
+		err = n.checkGuardrails(host, summary)
+		if err != nil {
+			fmt.Printf("%+v", diffResult)
+			n.discard(session, host)
+			return recordFailure(host, phaseGuardrails, err)
+		}
+	}
+
+	if n.approver != nil {
+		decision, approveErr := n.approve(host, diffResult)
+		if approveErr != nil {
+			_ = level.Error(n.logger).Log("msg", "approval failed", "host", host.HostName, "err", approveErr)
+		}
+
+		switch decision {
+		case DecisionCommit:
+			commitNow = true
+		case DecisionCommitConfirmed:
+			commitNow = true
+			if confirm == 0 {
+				confirm = defaultConfirmMinutes
 			}
+		default:
+			commitNow = false
+			_ = level.Info(n.logger).Log("msg", "skipping commit", "host", host.HostName)
+		}
+	} else {
+		fmt.Printf("%+v", diffResult)
+	}
 
-			err = session.Commit()
-			if err != nil {
-				return err
+	if !commitNow {
+		if guarded {
+			n.releaseGuardrails(summary)
+		}
+		return discardCandidate(session)
+	}
+
+	// Until the change is committed, or scheduled, a failure releases its
+	// share of the run guardrails and discards the candidate.
+	var committed bool
+	defer func() {
+		if !committed {
+			n.releaseGuardrails(summary)
+			n.discard(session, host)
+		}
+	}()
+
+	at, err := n.commitTime(host)
+	if err != nil {
+		return recordFailure(host, phaseCommit, err)
+	}
+
+	risks, err := n.lockoutRisks(session, host, summary)
+	if err != nil {
+		return recordFailure(host, phaseLockout, err)
+	}
+
+	// Changes which may remove our own access are committed with commit
+	// confirmed, and only confirmed once a new session can be opened.
+	var verify bool
+	if len(risks) > 0 {
+		_ = level.Warn(n.logger).Log("msg", "change affects management access", "host", host.HostName, "risks", strings.Join(risks, "; "))
+
+		switch {
+		case n.cfg.Lockout == LockoutRefuse:
+			return recordFailure(host, phaseLockout, fmt.Errorf("refusing to commit changes affecting management access to %s: %s", host.HostName, strings.Join(risks, "; ")))
+		case at != "":
+			return recordFailure(host, phaseLockout, fmt.Errorf("refusing to schedule changes affecting management access to %s, commit them with commit confirmed: %s", host.HostName, strings.Join(risks, "; ")))
+		case confirm == 0:
+			confirm = defaultConfirmMinutes
+			verify = true
+		}
+	}
+
+	// The operational state is captured before the commit, to be compared
+	// with the state after it.  Scheduled commits are not checked.
+	var (
+		checks []data.Check
+		before []checkState
+	)
+	if at == "" {
+		checks = n.hostChecks(host)
+	}
+	if len(checks) > 0 {
+		n.progress(host, phaseVerify)
+
+		before, err = captureChecks(session, checks)
+		if err != nil {
+			return recordFailure(host, phaseVerify, fmt.Errorf("refusing to commit to %s without the state of its checks: %w", host.HostName, err))
+		}
+	}
+
+	// The commit of a device with more than one routing engine is
+	// synchronized, and its result compared with the routing engines of the
+	// device, so that a backup which did not commit is reported.
+	var engines []routeEngine
+	if at == "" && session.RoutingEngines > 1 {
+		engines, err = chassisRoutingEngines(session)
+		if err != nil {
+			return recordFailure(host, phaseCommit, fmt.Errorf("refusing to commit to %s without the state of its routing engines: %w", host.HostName, err))
+		}
+	}
+
+	n.progress(host, phaseCommit)
+
+	_, commitSpan := tracer().Start(ctx, "Commit", hostAttributes(host),
+		trace.WithAttributes(attrCommitAt.String(at), attrCommitConfirm.Int(confirm)))
+
+	if at != "" {
+		_ = level.Info(n.logger).Log("msg", "scheduling commit", "host", host.HostName, "at", at)
+		err = commitAt(session, at, n.provenance.Comment())
+		endSpan(commitSpan, err)
+		committed = err == nil
+		return recordFailure(host, phaseCommit, err)
+	}
+
+	results, err := commitRoutingEngines(session, n.provenance.Comment(), confirm)
+	endSpan(commitSpan, err)
+	if err != nil {
+		return recordFailure(host, phaseCommit, err)
+	}
+	committed = true
+	metricCommits.WithLabelValues(host.HostName).Inc()
+	n.updateResult(host, func(r *HostResult) { r.Committed = true })
+
+	if len(engines) > 1 {
+		err = n.checkRoutingEngines(host, engines, results)
+		if err != nil {
+			return recordFailure(host, phaseCommit, err)
+		}
+	}
+
+	if verify {
+		err = n.verifyAccess(host)
+		if err != nil {
+			return recordFailure(host, phaseLockout, fmt.Errorf("unable to reach %s after commit confirmed, the device will roll back in %d minutes: %w", host.HostName, confirm, err))
+		}
+	}
+
+	// A change committed with commit confirmed whose checks regress is rolled
+	// back, and otherwise confirmed.
+	if len(checks) > 0 {
+		n.progress(host, phaseVerify)
+
+		err = n.verifyChecks(session, host, checks, before, confirm)
+		if err != nil && confirm > 0 {
+			if pendingErr := n.confirmPending(session, host); pendingErr != nil {
+				return recordFailure(host, phaseVerify, fmt.Errorf("%s, and %w", err, pendingErr))
 			}
-		} else {
-			err = session.Config("rollback", "text", false)
-			if err != nil {
-				return err
+
+			rollbackErr := n.rollbackConfirmed(session, host)
+			if rollbackErr != nil {
+				return recordFailure(host, phaseVerify, fmt.Errorf("%s, and the rollback failed, the device will roll back in %d minutes: %w", err, confirm, rollbackErr))
 			}
+			return recordFailure(host, phaseVerify, fmt.Errorf("%w, the change was rolled back", err))
+		}
+		if err != nil {
+			return recordFailure(host, phaseVerify, err)
 		}
 	}
 
-	return nil
+	if confirm == 0 {
+		return nil
+	}
+
+	// A commit confirmed requested by the operator is left for them to
+	// confirm, and rolls back unless they do.
+	if !verify && len(checks) == 0 {
+		n.updateResult(host, func(r *HostResult) { r.Unconfirmed = true })
+		return nil
+	}
+
+	err = n.confirmPending(session, host)
+	if err != nil {
+		return recordFailure(host, phaseCommit, err)
+	}
+
+	_ = level.Info(n.logger).Log("msg", "confirming commit", "host", host.HostName)
+
+	return recordFailure(host, phaseCommit, commit(session, n.provenance.Comment(), 0))
+}
+
+// discard discards the candidate configuration of a host which is being
+// refused, logging any failure.
+func (n *NetConfig) discard(session *junos.Junos, host Host) {
+	err := discardCandidate(session)
+	if err != nil {
+		_ = level.Error(n.logger).Log("msg", "failed to discard candidate", "host", host.HostName, "err", err)
+	}
+}
+
+// RenderHost renders all of the templates for a host, returning the
+// configuration which would be loaded onto the device.
+func (n *NetConfig) RenderHost(host Host) (string, error) {
+	return n.renderHost(context.Background(), host)
+}
+
+func (n *NetConfig) renderHost(ctx context.Context, host Host) (string, error) {
+	err := n.allocateHosts(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return n.renderHostTemplates(ctx, host)
+}
+
+// allocateHosts renders every host once, before any host is rendered for use,
+// to collect the keys requested by the templates which have no allocation,
+// and allocates them in order.  This keeps the allocations independent of the
+// order in which the hosts are rendered concurrently.
+func (n *NetConfig) allocateHosts(ctx context.Context) error {
+	n.allocateOnce.Do(func() {
+		if len(n.allocator.pools) == 0 {
+			return
+		}
+
+		n.allocator.collect()
+
+		// The errors are those of rendering, which are reported when the
+		// hosts are rendered again.
+		wg := sync.WaitGroup{}
+		for _, h := range n.Hosts {
+			wg.Add(1)
+			go func(h Host) {
+				defer wg.Done()
+				_, _ = n.renderHostTemplates(ctx, h)
+			}(h)
+		}
+		wg.Wait()
+
+		n.allocateErr = n.allocator.allocatePending()
+	})
+
+	return n.allocateErr
+}
+
+func (n *NetConfig) renderHostTemplates(ctx context.Context, host Host) (_ string, err error) {
+	ctx, span := tracer().Start(ctx, "RenderHost", hostAttributes(host))
+	defer func() { endSpan(span, err) }()
+
+	templates := n.templatesForDevice(host)
+
+	_ = level.Debug(n.logger).Log("msg", "templates for device", "count", len(templates))
+
+	var renderedTemplates []string
+	for _, t := range templates {
+		_, templateSpan := tracer().Start(ctx, "RenderTemplate", hostAttributes(host), trace.WithAttributes(attrTemplate.String(t)))
+		result, err := n.renderHostTemplateFile(host, t)
+		endSpan(templateSpan, err)
+		if err != nil {
+			return "", err
+		}
+		renderedTemplates = append(renderedTemplates, result)
+	}
+
+	return strings.Join(renderedTemplates, "\n"), nil
+}
+
+// renderHosts renders the hosts concurrently, returning the rendered
+// configuration and error of each host in the order of the hosts.
+func (n *NetConfig) renderHosts(hosts []Host) ([]string, []error) {
+	rendered := make([]string, len(hosts))
+	errs := make([]error, len(hosts))
+
+	wg := sync.WaitGroup{}
+	for i := range hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rendered[i], errs[i] = n.RenderHost(hosts[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return rendered, errs
 }
 
 // DataForDevice returns HostData for a given NetworkHost.
-func (n *NetConfig) dataForHost(host Host) (data.HostData, error) {
-	hostData := data.HostData{}
+func (n *NetConfig) dataForHost(ctx context.Context, host Host) (hostData data.HostData, err error) {
+	ctx, span := tracer().Start(ctx, "DataForHost", hostAttributes(host))
+	defer func() { endSpan(span, err) }()
 
 	for _, f := range n.hierarchyForDevice(host) {
-		fileHostData := data.HostData{}
-		err := loadHostDataFile(f, &fileHostData)
+		_, fileSpan := tracer().Start(ctx, "LoadHostDataFile", trace.WithAttributes(attrFile.String(f)))
+
+		var fileHostData data.HostData
+		fileHostData, err = n.cache.hostData(f)
 		if err != nil {
-			return hostData, errors.Wrap(err, "failed to load yaml file "+f)
+			err = errors.Wrap(err, "failed to load yaml file "+f)
+			endSpan(fileSpan, err)
+			return hostData, err
 		}
 
-		if err := mergo.Merge(&hostData, fileHostData, mergo.WithOverride); err != nil {
+		if err = mergo.Merge(&hostData, fileHostData, mergo.WithOverride); err != nil {
 			_ = level.Error(n.logger).Log("msg", "failed to merge data", "err", err)
-			return hostData, errors.Wrap(err, "failed to merge data")
+			err = errors.Wrap(err, "failed to merge data")
+			endSpan(fileSpan, err)
+			return hostData, err
 		}
+
+		fileSpan.End()
 	}
 
 	return hostData, nil
@@ -248,7 +922,7 @@ func (n *NetConfig) hierarchyForDevice(host Host) []string {
 
 	for _, p := range paths {
 		templateAbs := fmt.Sprintf("%s/data/%s", n.cfg.Data.Directory, p)
-		if _, err := os.Stat(templateAbs); err == nil {
+		if n.cache.exists(templateAbs) {
 			files = append(files, templateAbs)
 		} else {
 			_ = level.Debug(n.logger).Log("msg", "template file not found", "file", templateAbs)
@@ -268,9 +942,9 @@ func (n *NetConfig) templatesForDevice(host Host) []string {
 
 	for _, p := range paths {
 		templateAbs := fmt.Sprintf("%s/%s/%s", n.cfg.Data.Directory, n.Data.TemplateDir, p)
-		if _, err := os.Stat(templateAbs); err == nil {
+		if n.cache.exists(templateAbs) {
 			globPattern := fmt.Sprintf("%s/*.tmpl", templateAbs)
-			foundFiles, globErr := filepath.Glob(globPattern)
+			foundFiles, globErr := n.cache.glob(globPattern)
 			if globErr != nil {
 				_ = level.Error(n.logger).Log("msg", "failed to glob pattern", "err", globErr)
 			} else {
@@ -284,15 +958,9 @@ func (n *NetConfig) templatesForDevice(host Host) []string {
 
 // RenderHostTemplateFile renders a template file using a Host object.
 func (n *NetConfig) renderHostTemplateFile(host Host, path string) (string, error) {
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to read path "+path)
-	}
-
-	str := string(b)
-	tmpl, err := template.New("test").Parse(str)
+	tmpl, err := n.cache.template(path, n.templateFuncs())
 	if err != nil {
-		return "", errors.Wrap(err, "failed to parse template "+path)
+		return "", err
 	}
 
 	var buf bytes.Buffer
@@ -0,0 +1,225 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// l3Network is an L3Network of the inventory, with the inet and inet6 networks
+// which belong to it.
+type l3Network struct {
+	network *inventory.L3Network
+	inet    []*inventory.InetNetwork
+	inet6   []*inventory.Inet6Network
+}
+
+// listL3Networks returns the L3Networks of the inventory by name.  An inet or
+// inet6 network belongs to an L3Network when it is named by the L3Network,
+// when its entry is below that of the L3Network, or when it has the same name.
+func listL3Networks(ctx context.Context, inv inventory.Inventory) (map[string]*l3Network, error) {
+	l3, err := inv.ListL3Networks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list l3 networks")
+	}
+
+	inet, err := inv.ListInetNetworks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list inet networks")
+	}
+
+	inet6, err := inv.ListInet6Networks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list inet6 networks")
+	}
+
+	networks := map[string]*l3Network{}
+
+	for i := range l3 {
+		l := &l3Network{network: &l3[i]}
+
+		var inetNames, inet6Names []string
+		for _, n := range l3[i].InetNetwork {
+			inetNames = append(inetNames, n.Name)
+		}
+		for _, n := range l3[i].Inet6Network {
+			inet6Names = append(inet6Names, n.Name)
+		}
+
+		for j := range inet {
+			if belongsToNetwork(l.network, inetNames, inet[j].Name, inet[j].Dn) {
+				l.inet = append(l.inet, &inet[j])
+			}
+		}
+
+		for j := range inet6 {
+			if belongsToNetwork(l.network, inet6Names, inet6[j].Name, inet6[j].Dn) {
+				l.inet6 = append(l.inet6, &inet6[j])
+			}
+		}
+
+		networks[l3[i].Name] = l
+	}
+
+	return networks, nil
+}
+
+func belongsToNetwork(l *inventory.L3Network, names []string, name, dn string) bool {
+	if containsString(names, name) || name == l.Name {
+		return true
+	}
+
+	return l.Dn != "" && strings.HasSuffix(dn, ","+l.Dn)
+}
+
+// applyInventoryNetworks adds the VLANs, IRB interfaces and router
+// advertisements derived from the inventory networks routed on the host to its
+// data.  Those already present in the data of the host, by VLAN name or ID,
+// IRB unit, or router advertisement interface and prefix, take precedence.
+func (n *NetConfig) applyInventoryNetworks(host *Host, networks map[string]*l3Network) error {
+	d := &host.Data
+
+	// The slices of the data may be shared with the cache, and are copied
+	// before being appended to.
+	vlans := append([]data.VLAN(nil), d.VLANs...)
+	irbs := append([]data.IRBInterface(nil), d.IRBInterfaces...)
+	ras := append([]data.RouterAdvertisement(nil), d.RouterAdvertisements...)
+
+	for _, in := range n.Data.InventoryNetworks {
+		if !hostMatches(in.Roles, in.Hosts, *host) {
+			continue
+		}
+
+		l, ok := networks[in.L3Network]
+		if !ok {
+			return fmt.Errorf("l3 network %q not found in inventory", in.L3Network)
+		}
+
+		unit := in.Unit
+		if unit == "" {
+			unit = strconv.Itoa(in.VLANID)
+		}
+		ifname := "irb." + unit
+
+		name := in.VLANName
+		if name == "" {
+			name = l.network.Name
+		}
+
+		if !hasVLAN(vlans, name, in.VLANID) {
+			vlans = append(vlans, data.VLAN{
+				Name:        name,
+				ID:          in.VLANID,
+				Description: l.network.Description,
+				L3Interface: ifname,
+			})
+		}
+
+		if !hasIRBUnit(irbs, unit) {
+			irb := data.IRBInterface{Unit: unit, MTU: in.MTU}
+
+			for _, i := range l.inet {
+				addr, err := gatewayAddress(i.Gateway, i.Prefix)
+				if err != nil {
+					return errors.Wrapf(err, "invalid inet network %s", i.Name)
+				}
+				if addr != "" {
+					irb.Inet = append(irb.Inet, addr)
+				}
+			}
+
+			for _, i := range l.inet6 {
+				addr, err := gatewayAddress(i.Gateway, i.Prefix)
+				if err != nil {
+					return errors.Wrapf(err, "invalid inet6 network %s", i.Name)
+				}
+				if addr != "" {
+					irb.Inet6 = append(irb.Inet6, addr)
+				}
+			}
+
+			irbs = append(irbs, irb)
+		}
+
+		if !in.RouterAdvertisement {
+			continue
+		}
+
+		for _, i := range l.inet6 {
+			if hasRouterAdvertisement(ras, ifname, i.Prefix) {
+				continue
+			}
+
+			ras = append(ras, data.RouterAdvertisement{
+				Interface: ifname,
+				DNSServer: in.DNSServer,
+				Prefix:    i.Prefix,
+				Managed:   in.Managed,
+			})
+		}
+	}
+
+	d.VLANs = vlans
+	d.IRBInterfaces = irbs
+	d.RouterAdvertisements = ras
+
+	return nil
+}
+
+// gatewayAddress returns the address of the gateway with the length of the
+// prefix, eg: "192.0.2.1/24", or an empty string when there is no gateway.
+func gatewayAddress(gateway, prefix string) (string, error) {
+	if gateway == "" {
+		return "", nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	if ip := net.ParseIP(gateway); ip == nil || !ipnet.Contains(ip) {
+		return "", fmt.Errorf("gateway %s is not within %s", gateway, prefix)
+	}
+
+	ones, _ := ipnet.Mask.Size()
+
+	return fmt.Sprintf("%s/%d", gateway, ones), nil
+}
+
+func hasVLAN(vlans []data.VLAN, name string, id int) bool {
+	for _, v := range vlans {
+		if v.Name == name || (id != 0 && v.ID == id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasIRBUnit(irbs []data.IRBInterface, unit string) bool {
+	for _, i := range irbs {
+		if i.Unit == unit {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasRouterAdvertisement(ras []data.RouterAdvertisement, ifname, prefix string) bool {
+	for _, r := range ras {
+		if r.Interface == ifname && r.Prefix == prefix {
+			return true
+		}
+	}
+
+	return false
+}
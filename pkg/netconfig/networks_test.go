@@ -0,0 +1,125 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func TestInventoryNetworks(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+hierarchy:
+  - "global.yaml"
+  - "host/{{ .NetworkHost.Name }}.yaml"
+inventory_networks:
+  - l3_network: lab
+    vlan_id: 10
+    roles: [core]
+    router_advertisement: true
+    dns_server: 2001:db8:10::53
+  - l3_network: mgmt
+    vlan_id: 20
+    vlan_name: management
+    unit: "200"
+    hosts: [router2]
+`,
+		"data/global.yaml": `
+ntp_servers:
+  - 10.0.0.1
+`,
+		"data/host/router1.yaml": `
+vlans:
+  - name: users
+    id: 30
+`,
+	})
+
+	inv := &inventory.MockInventory{
+		ListNetworkHostResponse: []inventory.NetworkHost{
+			{Name: "router1", Domain: "example.com", Platform: "junos", Role: "core"},
+			{Name: "router2", Domain: "example.com", Platform: "junos", Role: "edge"},
+		},
+		ListL3NetworkResponse: []inventory.L3Network{
+			{Name: "lab", Description: "Lab network", Dn: "cn=lab,ou=network,dc=example,dc=com"},
+			{Name: "mgmt"},
+		},
+		ListInetNetworkResponse: []inventory.InetNetwork{
+			{Name: "lab4", Prefix: "192.0.2.0/24", Gateway: "192.0.2.1", Dn: "cn=lab4,cn=lab,ou=network,dc=example,dc=com"},
+			{Name: "mgmt", Prefix: "198.51.100.0/25", Gateway: "198.51.100.1"},
+			{Name: "other", Prefix: "203.0.113.0/24", Gateway: "203.0.113.1"},
+		},
+		ListInet6NetworkResponse: []inventory.Inet6Network{
+			{Name: "lab6", Prefix: "2001:db8:10::/64", Gateway: "2001:db8:10::1", Dn: "cn=lab6,cn=lab,ou=network,dc=example,dc=com"},
+		},
+	}
+
+	n, err := NewWithInventory(Config{Data: DataConfig{Directory: dir}}, inv, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, n.Hosts, 2)
+
+	router1, err := n.Host("router1")
+	require.NoError(t, err)
+
+	require.Equal(t, []data.VLAN{
+		{Name: "users", ID: 30},
+		{Name: "lab", ID: 10, Description: "Lab network", L3Interface: "irb.10"},
+	}, router1.Data.VLANs)
+	require.Equal(t, []data.IRBInterface{
+		{Unit: "10", Inet: []string{"192.0.2.1/24"}, Inet6: []string{"2001:db8:10::1/64"}},
+	}, router1.Data.IRBInterfaces)
+	require.Equal(t, []data.RouterAdvertisement{
+		{Interface: "irb.10", Prefix: "2001:db8:10::/64", DNSServer: "2001:db8:10::53"},
+	}, router1.Data.RouterAdvertisements)
+
+	router2, err := n.Host("router2")
+	require.NoError(t, err)
+
+	require.Equal(t, []data.VLAN{
+		{Name: "management", ID: 20, L3Interface: "irb.200"},
+	}, router2.Data.VLANs)
+	require.Equal(t, []data.IRBInterface{
+		{Unit: "200", Inet: []string{"198.51.100.1/25"}},
+	}, router2.Data.IRBInterfaces)
+	require.Empty(t, router2.Data.RouterAdvertisements)
+}
+
+func TestInventoryNetworksMissing(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+inventory_networks:
+  - l3_network: lab
+    vlan_id: 10
+`,
+	})
+
+	inv := &inventory.MockInventory{
+		ListNetworkHostResponse: []inventory.NetworkHost{
+			{Name: "router1", Domain: "example.com", Platform: "junos", Role: "core"},
+		},
+	}
+
+	_, err := NewWithInventory(Config{Data: DataConfig{Directory: dir}}, inv, log.NewNopLogger())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `l3 network "lab" not found in inventory`)
+}
+
+func TestGatewayAddress(t *testing.T) {
+	addr, err := gatewayAddress("192.0.2.1", "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.1/24", addr)
+
+	addr, err = gatewayAddress("", "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Empty(t, addr)
+
+	_, err = gatewayAddress("198.51.100.1", "192.0.2.0/24")
+	require.Error(t, err)
+
+	_, err = gatewayAddress("192.0.2.1", "192.0.2.0")
+	require.Error(t, err)
+}
@@ -0,0 +1,224 @@
+package netconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/scottdware/go-junos"
+)
+
+// preflightMounts are the filesystems whose free space is checked before a
+// host is configured, as mounted on older and newer releases.
+var preflightMounts = []string{"/", "/var", "/config", "/.mount", "/.mount/var", "/.mount/config"}
+
+type alarmDetail struct {
+	Class       string `xml:"alarm-class"`
+	Description string `xml:"alarm-description"`
+}
+
+type routeEngine struct {
+	Slot            string `xml:"slot"`
+	MastershipState string `xml:"mastership-state"`
+	Status          string `xml:"status"`
+}
+
+type filesystem struct {
+	Name        string `xml:"filesystem-name"`
+	UsedPercent string `xml:"used-percent"`
+	MountedOn   string `xml:"mounted-on"`
+}
+
+// decodeElements decodes each element of the given name found in the xml
+// output into a new value, which is passed to fn.  The elements are found at
+// any depth, so that the output of each routing engine of a multi routing
+// engine reply is included.
+func decodeElements(output, name string, newValue func() interface{}, fn func(interface{})) error {
+	d := xml.NewDecoder(strings.NewReader(output))
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != name {
+			continue
+		}
+
+		v := newValue()
+		err = d.DecodeElement(v, &start)
+		if err != nil {
+			return err
+		}
+
+		fn(v)
+	}
+}
+
+// preflight checks the health of the host before it is locked, returning a
+// description of each failure: active major alarms, routing engines which are
+// not healthy or not in sync, too little free disk space, and a Junos version
+// outside of the configured range.
+func (n *NetConfig) preflight(session *junos.Junos) ([]string, error) {
+	var failures []string
+
+	for _, command := range []string{"show chassis alarms", "show system alarms"} {
+		output, err := session.Command(command, "xml")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to run %q", command)
+		}
+
+		err = decodeElements(output, "alarm-detail", func() interface{} { return &alarmDetail{} }, func(v interface{}) {
+			a := v.(*alarmDetail)
+			if strings.EqualFold(strings.TrimSpace(a.Class), "Major") {
+				failures = append(failures, fmt.Sprintf("major alarm: %s", strings.TrimSpace(a.Description)))
+			}
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the output of %q", command)
+		}
+	}
+
+	engines, err := chassisRoutingEngines(session)
+	if err != nil {
+		return nil, err
+	}
+
+	failures = append(failures, routeEngineFailures(engines, session.Platform)...)
+
+	output, err := session.Command("show system storage", "xml")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run \"show system storage\"")
+	}
+
+	err = decodeElements(output, "filesystem", func() interface{} { return &filesystem{} }, func(v interface{}) {
+		fs := v.(*filesystem)
+		if !containsString(preflightMounts, strings.TrimSpace(fs.MountedOn)) {
+			return
+		}
+
+		used, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(fs.UsedPercent), "%")))
+		if err != nil {
+			return
+		}
+
+		if free := 100 - used; free < n.cfg.Preflight.MinFreeDisk {
+			failures = append(failures, fmt.Sprintf("%d%% free on %s, less than %d%%", free, strings.TrimSpace(fs.MountedOn), n.cfg.Preflight.MinFreeDisk))
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the output of \"show system storage\"")
+	}
+
+	for _, re := range session.Platform {
+		if n.cfg.Preflight.MinVersion != "" && compareVersions(re.Version, n.cfg.Preflight.MinVersion) < 0 {
+			failures = append(failures, fmt.Sprintf("junos %s is older than %s", re.Version, n.cfg.Preflight.MinVersion))
+		}
+		if n.cfg.Preflight.MaxVersion != "" && compareVersions(re.Version, n.cfg.Preflight.MaxVersion) > 0 {
+			failures = append(failures, fmt.Sprintf("junos %s is newer than %s", re.Version, n.cfg.Preflight.MaxVersion))
+		}
+	}
+
+	return failures, nil
+}
+
+// routeEngineFailures returns a description of each routing engine which is
+// not healthy, and of a device which has no single master, or whose routing
+// engines run different versions.
+func routeEngineFailures(engines []routeEngine, platform []junos.RoutingEngine) []string {
+	var (
+		failures []string
+		masters  int
+	)
+
+	for _, re := range engines {
+		slot := strings.TrimSpace(re.Slot)
+
+		if status := strings.TrimSpace(re.Status); status != "" && !strings.EqualFold(status, "OK") {
+			failures = append(failures, fmt.Sprintf("routing engine %s status is %s", slot, status))
+		}
+
+		if strings.EqualFold(strings.TrimSpace(re.MastershipState), "master") {
+			masters++
+		}
+	}
+
+	if len(engines) > 1 && masters != 1 {
+		failures = append(failures, fmt.Sprintf("%d of %d routing engines are master", masters, len(engines)))
+	}
+
+	for _, re := range platform {
+		if re.Version != platform[0].Version {
+			failures = append(failures, fmt.Sprintf("routing engines are not in sync, running %s and %s", platform[0].Version, re.Version))
+			break
+		}
+	}
+
+	return failures
+}
+
+// versionParts splits a Junos version such as "20.4R3-S1.3" into its runs of
+// digits and letters, ie "20", "4", "R", "3", "S", "1", "3".
+func versionParts(version string) []string {
+	var parts []string
+
+	for _, field := range strings.FieldsFunc(version, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		start := 0
+		for i := 1; i <= len(field); i++ {
+			if i == len(field) || unicode.IsDigit(rune(field[i])) != unicode.IsDigit(rune(field[i-1])) {
+				parts = append(parts, field[start:i])
+				start = i
+			}
+		}
+	}
+
+	return parts
+}
+
+// compareVersions compares two Junos versions up to the precision of the
+// second, so that "20.4R3.8" is equal to "20.4".  Numbers are compared
+// numerically, and are older than letters.
+func compareVersions(version, other string) int {
+	parts := versionParts(version)
+	otherParts := versionParts(other)
+
+	for i, o := range otherParts {
+		if i >= len(parts) {
+			return -1
+		}
+
+		a, aErr := strconv.Atoi(parts[i])
+		b, bErr := strconv.Atoi(o)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if a != b {
+				if a < b {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(parts[i], o); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
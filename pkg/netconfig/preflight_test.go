@@ -0,0 +1,99 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/scottdware/go-junos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureNetworkPreflight(t *testing.T) {
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Preflight: PreflightConfig{Enabled: true, MinFreeDisk: 10}}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.Contains(t, device.Running(), "server 10.0.0.2;")
+	require.Empty(t, n.Report().Hosts[0].Preflight)
+}
+
+func TestConfigureNetworkPreflightFailed(t *testing.T) {
+	device := newTestDevice(t)
+	device.SetCommand("show chassis alarms", `<alarm-information>
+<alarm-summary><active-alarm-count>2</active-alarm-count></alarm-summary>
+<alarm-detail><alarm-class>Major</alarm-class><alarm-description>PEM 0 Not OK</alarm-description></alarm-detail>
+<alarm-detail><alarm-class>Minor</alarm-class><alarm-description>Rescue configuration is not set</alarm-description></alarm-detail>
+</alarm-information>`)
+	device.SetCommand("show system storage", `<system-storage-information>
+<filesystem><filesystem-name>/dev/gpt/var</filesystem-name><used-percent> 97</used-percent><mounted-on>/.mount/var</mounted-on></filesystem>
+<filesystem><filesystem-name>tmpfs</filesystem-name><used-percent>100</used-percent><mounted-on>/.mount/tmp</mounted-on></filesystem>
+</system-storage-information>`)
+
+	n := newTestNetConfig(t, Config{
+		Commit:    true,
+		Preflight: PreflightConfig{Enabled: true, MinFreeDisk: 10, MinVersion: "21.2"},
+	}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+
+	// The host is neither locked nor configured.
+	require.Empty(t, device.Commits())
+	require.NotContains(t, device.RPCs(), "lock-configuration")
+
+	require.Equal(t, []string{
+		"major alarm: PEM 0 Not OK",
+		"3% free on /.mount/var, less than 10%",
+		"junos 20.4R3.8 is older than 21.2",
+	}, n.Report().Hosts[0].Preflight)
+}
+
+func TestRouteEngineFailures(t *testing.T) {
+	require.Empty(t, routeEngineFailures([]routeEngine{
+		{Slot: "0", MastershipState: "master", Status: "OK"},
+		{Slot: "1", MastershipState: "backup", Status: "OK"},
+	}, []junos.RoutingEngine{{Version: "20.4R3.8"}, {Version: "20.4R3.8"}}))
+
+	require.Equal(t, []string{
+		"routing engine 1 status is Testing",
+		"2 of 2 routing engines are master",
+		"routing engines are not in sync, running 20.4R3.8 and 20.4R2.7",
+	}, routeEngineFailures([]routeEngine{
+		{Slot: "0", MastershipState: "master", Status: "OK"},
+		{Slot: "1", MastershipState: "master", Status: "Testing"},
+	}, []junos.RoutingEngine{{Version: "20.4R3.8"}, {Version: "20.4R2.7"}}))
+}
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		version, other string
+		result         int
+	}{
+		{"20.4R3.8", "20.4", 0},
+		{"20.4R3.8", "20.4R3", 0},
+		{"20.4R3.8", "20.4R4", -1},
+		{"20.4R3-S1.3", "20.4R3.8", 1},
+		{"21.2R3-S4.8", "20.4", 1},
+		{"19.4R1.10", "20.4", -1},
+		{"15.1X49-D200.3", "15.1R7", 1},
+		{"20.4", "20.4R3", -1},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.result, compareVersions(tc.version, tc.other), "%s %s", tc.version, tc.other)
+	}
+}
+
+func TestRollbackPreflightFailed(t *testing.T) {
+	device := newTestDevice(t)
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	device.SetCommand("show chassis alarms", `<alarm-information>
+<alarm-detail><alarm-class>Major</alarm-class><alarm-description>PEM 0 Not OK</alarm-description></alarm-detail>
+</alarm-information>`)
+
+	undo := newTestNetConfig(t, Config{Commit: true, Preflight: PreflightConfig{Enabled: true}}, device)
+	require.Error(t, undo.RollbackNetwork(n.Provenance().RunID, 0))
+	require.Equal(t, []string{"major alarm: PEM 0 Not OK"}, undo.Report().Hosts[0].Preflight)
+	require.Len(t, device.Commits(), 1)
+}
@@ -0,0 +1,106 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureNetworkPrivate(t *testing.T) {
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Private: true}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.Contains(t, device.Running(), "server 10.0.0.2;")
+	require.Equal(t, device.Running(), device.Candidate())
+
+	rpcs := device.RPCs()
+	require.Contains(t, rpcs, "open-configuration")
+	require.Contains(t, rpcs, "close-configuration")
+	require.NotContains(t, rpcs, "lock-configuration")
+}
+
+func TestConfigureNetworkPrivateDiff(t *testing.T) {
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Private: true}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.True(t, n.Report().Hosts[0].Changed)
+	require.Empty(t, device.Commits())
+	require.NotContains(t, device.RPCs(), "lock-configuration")
+}
+
+func TestConfigureNetworkUncommitted(t *testing.T) {
+	for _, private := range []bool{false, true} {
+		device := newTestDevice(t)
+		require.NoError(t, device.SetCandidate(testRunningConfig+"snmp { community public; }\n"))
+
+		n := newTestNetConfig(t, Config{Commit: true, Private: private}, device)
+
+		err := n.ConfigureNetwork()
+		require.Error(t, err)
+		require.Contains(t, n.Report().Hosts[0].Err.Error(), "refusing to configure router1.example.com, its candidate has uncommitted changes made by another user")
+
+		// The changes of the other user are reported and kept.
+		require.Contains(t, n.Report().Hosts[0].Uncommitted, "community public;")
+		require.Contains(t, device.Candidate(), "community public;")
+		require.Empty(t, device.Commits())
+		require.NotContains(t, device.RPCs(), "load-configuration")
+	}
+}
+
+func TestRollbackAndRestorePrivate(t *testing.T) {
+	device := newTestDevice(t)
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{Commit: true, Backup: BackupConfig{Directory: t.TempDir()}}, device)
+	_, err := n.BackupHost(n.Hosts[0])
+	require.NoError(t, err)
+	require.NoError(t, n.ConfigureNetwork())
+
+	private := newTestNetConfig(t, Config{Commit: true, Private: true, Backup: n.cfg.Backup}, device)
+	require.NoError(t, private.RollbackNetwork(n.Provenance().RunID, 0))
+	require.Equal(t, running, device.Running())
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.NoError(t, private.RestoreHost(private.Hosts[0], ""))
+	require.Equal(t, running, device.Running())
+
+	// Only the pushes locked the shared candidate.
+	var locks, opens int
+	for _, rpc := range device.RPCs() {
+		switch rpc {
+		case "lock-configuration":
+			locks++
+		case "open-configuration":
+			opens++
+		}
+	}
+	require.Equal(t, 2, locks)
+	require.Equal(t, 2, opens)
+	require.Equal(t, device.Running(), device.Candidate())
+}
+
+func TestRollbackAndRestoreUncommitted(t *testing.T) {
+	device := newTestDevice(t)
+
+	n := newTestNetConfig(t, Config{Commit: true, Backup: BackupConfig{Directory: t.TempDir()}}, device)
+	_, err := n.BackupHost(n.Hosts[0])
+	require.NoError(t, err)
+	require.NoError(t, n.ConfigureNetwork())
+
+	require.NoError(t, device.SetCandidate(device.Running()+"snmp { community public; }\n"))
+	commits := len(device.Commits())
+
+	// The changes of the other user are kept, rather than being committed or
+	// discarded with the rollback or restore.
+	err = n.RollbackNetwork(n.Provenance().RunID, 0)
+	require.Error(t, err)
+
+	err = n.RestoreHost(n.Hosts[0], "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "its candidate has uncommitted changes made by another user")
+
+	require.Contains(t, device.Candidate(), "community public;")
+	require.Len(t, device.Commits(), commits)
+}
@@ -0,0 +1,96 @@
+package netconfig
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commitCommentPrefix marks commits made by netconfig in the commit history
+// of a device.
+const commitCommentPrefix = "netconfig"
+
+// Provenance describes the origin of a change made by netconfig.  It is
+// recorded in the comment of every commit.
+type Provenance struct {
+	RunID    string
+	Operator string
+	Revision string
+}
+
+// Comment returns the commit comment for the provenance.
+func (p Provenance) Comment() string {
+	fields := []string{commitCommentPrefix, "run=" + p.RunID}
+
+	if p.Operator != "" {
+		fields = append(fields, "operator="+p.Operator)
+	}
+
+	if p.Revision != "" {
+		fields = append(fields, "revision="+p.Revision)
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// ParseCommitComment returns the Provenance recorded in a commit comment, and
+// whether the comment was written by netconfig.
+func ParseCommitComment(comment string) (Provenance, bool) {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 || fields[0] != commitCommentPrefix {
+		return Provenance{}, false
+	}
+
+	p := Provenance{}
+	for _, f := range fields[1:] {
+		parts := strings.SplitN(f, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch parts[0] {
+		case "run":
+			p.RunID = parts[1]
+		case "operator":
+			p.Operator = parts[1]
+		case "revision":
+			p.Revision = parts[1]
+		}
+	}
+
+	return p, p.RunID != ""
+}
+
+// newRunID returns a new identifier for a single netconfig run.
+func newRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(b))
+}
+
+// gitRevision returns the short git revision of the checkout containing dir,
+// suffixed with "-dirty" when there are uncommitted changes.  An empty string
+// is returned when dir is not a git checkout.
+func gitRevision(dir string) string {
+	if dir == "" {
+		return ""
+	}
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+
+	rev := strings.TrimSpace(string(out))
+
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		rev += "-dirty"
+	}
+
+	return rev
+}
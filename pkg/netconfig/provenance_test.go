@@ -0,0 +1,36 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenanceComment(t *testing.T) {
+	cases := []struct {
+		provenance Provenance
+		comment    string
+	}{
+		{
+			provenance: Provenance{RunID: "20220102T150405-abcdef", Operator: "zach", Revision: "1a2b3c4"},
+			comment:    "netconfig run=20220102T150405-abcdef operator=zach revision=1a2b3c4",
+		},
+		{
+			provenance: Provenance{RunID: "20220102T150405-abcdef"},
+			comment:    "netconfig run=20220102T150405-abcdef",
+		},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.comment, tc.provenance.Comment())
+
+		p, ok := ParseCommitComment(tc.comment)
+		require.True(t, ok)
+		require.Equal(t, tc.provenance, p)
+	}
+
+	for _, comment := range []string{"", "fixed the thing", "netconfig", "netconfigured run=1"} {
+		_, ok := ParseCommitComment(comment)
+		require.False(t, ok, comment)
+	}
+}
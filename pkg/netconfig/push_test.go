@@ -0,0 +1,242 @@
+package netconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/scottdware/go-junos"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/junostest"
+)
+
+const testRunningConfig = `
+system {
+    host-name router1;
+}
+`
+
+var testDataFiles = map[string]string{
+	"data.yaml": `
+template_dir: templates
+template_paths:
+  - "platform/{{ .NetworkHost.Platform }}"
+hierarchy:
+  - "global.yaml"
+  - "host/{{ .NetworkHost.Name }}.yaml"
+`,
+	"data/global.yaml": `
+ntp_servers:
+  - 10.0.0.1
+`,
+	"data/host/router1.yaml": `
+ntp_servers:
+  - 10.0.0.2
+`,
+	"templates/platform/junos/system.tmpl": `
+system {
+    host-name {{ .NetworkHost.Name }};
+    ntp {
+{{- range .Data.NTPServers }}
+        server {{ . }};
+{{- end }}
+    }
+}
+`,
+}
+
+// writeTestData writes the files to a new data directory, returning its path.
+func writeTestData(t testing.TB, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	return dir
+}
+
+// newTestNetConfig returns a NetConfig for a single junos host named router1,
+// whose sessions are opened to the given simulated device.
+func newTestNetConfig(t *testing.T, cfg Config, device *junostest.Server) *NetConfig {
+	if cfg.Data.Directory == "" {
+		cfg.Data.Directory = writeTestData(t, testDataFiles)
+	}
+
+	inv := &inventory.MockInventory{
+		ListNetworkHostResponse: []inventory.NetworkHost{
+			{Name: "router1", Domain: "example.com", Platform: "junos", Role: "core"},
+		},
+	}
+
+	n, err := NewWithInventory(cfg, inv, log.NewNopLogger())
+	require.NoError(t, err)
+
+	n.SetSessionDialer(func(host Host) (*junos.Junos, error) {
+		return device.Dial()
+	})
+
+	return n
+}
+
+func newTestDevice(t *testing.T) *junostest.Server {
+	device, err := junostest.NewServer("router1", testRunningConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = device.Close() })
+
+	return device
+}
+
+func TestConfigureNetworkCommit(t *testing.T) {
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true, Operator: "tester"}, device)
+
+	require.Len(t, n.Hosts, 1)
+	require.Equal(t, []string{"10.0.0.2"}, n.Hosts[0].Data.NTPServers)
+
+	require.NoError(t, n.ConfigureNetwork())
+
+	require.Contains(t, device.Running(), "server 10.0.0.2;")
+	require.False(t, device.Locked())
+
+	commits := device.Commits()
+	require.Len(t, commits, 1)
+
+	p, ok := ParseCommitComment(commits[0].Log)
+	require.True(t, ok)
+	require.Equal(t, n.Provenance().RunID, p.RunID)
+	require.Equal(t, "tester", p.Operator)
+
+	// A second run has nothing to change.
+	require.NoError(t, n.ConfigureNetwork())
+	require.Len(t, device.Commits(), 1)
+}
+
+func TestConfigureNetworkWithoutCommit(t *testing.T) {
+	device := newTestDevice(t)
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	require.Equal(t, running, device.Running())
+	require.Equal(t, running, device.Candidate())
+	require.Empty(t, device.Commits())
+	require.False(t, device.Locked())
+}
+
+func TestConfigureNetworkCommitConfirmed(t *testing.T) {
+	device := newTestDevice(t)
+	device.ConfirmUnit = 50 * time.Millisecond
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{Commit: true, CommitConfirmed: 1}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	require.Contains(t, device.Running(), "server 10.0.0.2;")
+	require.True(t, device.Commits()[0].Confirmed)
+
+	require.Eventually(t, func() bool {
+		return device.Running() == running
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConfigureNetworkCommitCheckFailure(t *testing.T) {
+	device := newTestDevice(t)
+	device.CommitCheck = func(config string) error {
+		if strings.Contains(config, "10.0.0.2") {
+			return errors.New("ntp server is not reachable")
+		}
+		return nil
+	}
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	err := n.ConfigureNetwork()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "router1.example.com")
+
+	require.Equal(t, running, device.Running())
+	require.False(t, device.Locked())
+}
+
+func TestRollbackNetworkRun(t *testing.T) {
+	device := newTestDevice(t)
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+	require.NotEqual(t, running, device.Running())
+
+	undo := newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, undo.RollbackNetwork(n.Provenance().RunID, 0))
+	require.Equal(t, running, device.Running())
+	require.Len(t, device.Commits(), 2)
+
+	// The run of the rollback itself made no earlier commit.
+	require.NoError(t, undo.RollbackNetwork("unknown-run", 0))
+	require.Len(t, device.Commits(), 2)
+}
+
+func TestBackupRestore(t *testing.T) {
+	device := newTestDevice(t)
+	running := device.Running()
+
+	n := newTestNetConfig(t, Config{Commit: true, Backup: BackupConfig{Directory: t.TempDir()}}, device)
+
+	path, err := n.BackupHost(n.Hosts[0])
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, running, string(b))
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.NotEqual(t, running, device.Running())
+
+	require.NoError(t, n.RestoreHost(n.Hosts[0], ""))
+	require.Equal(t, running, device.Running())
+}
+
+func TestRollbackNetworkReport(t *testing.T) {
+	device := newTestDevice(t)
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	require.NoError(t, n.ConfigureNetwork())
+
+	undo := newTestNetConfig(t, Config{Commit: true}, device)
+
+	var done []*HostResult
+	undo.SetProgressFunc(func(p Progress) {
+		if p.Phase == phaseDone {
+			done = append(done, p.Result)
+		}
+	})
+
+	// The rollback is reported as a push is.
+	require.NoError(t, undo.RollbackNetwork(n.Provenance().RunID, 0))
+	require.Len(t, done, 1)
+
+	report := undo.Report()
+	require.False(t, report.Finished.IsZero())
+	require.Len(t, report.Hosts, 1)
+	require.True(t, report.Hosts[0].Committed)
+	require.Contains(t, report.Hosts[0].Diff, "10.0.0.2")
+
+	// A host on which the run made no commit is skipped.
+	require.NoError(t, undo.RollbackNetwork("unknown-run", 0))
+	require.Len(t, done, 2)
+
+	report = undo.Report()
+	require.Len(t, report.Hosts, 1)
+	require.True(t, report.Hosts[0].Skipped)
+	require.False(t, report.Hosts[0].Committed)
+}
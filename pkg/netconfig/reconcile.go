@@ -0,0 +1,133 @@
+package netconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// ReconcileResult is the outcome of reconciling the network with the data
+// directory.  Changed names the hosts whose rendered configuration differs from
+// the configuration last applied, and Unchanged those which match it.  Report
+// is the report of the push of the changed hosts, and is empty for a dry run.
+type ReconcileResult struct {
+	Revision  string
+	Changed   []string
+	Unchanged []string
+	Report    RunReport
+}
+
+// Reconcile pushes the hosts whose rendered configuration changed since it was
+// last applied, as recorded in the state, and records the hosts which were
+// applied successfully.  When configured for a dry run, the changed hosts are
+// only reported.
+func (n *NetConfig) Reconcile(state *State) (ReconcileResult, error) {
+	result := ReconcileResult{Revision: n.provenance.Revision}
+
+	changed, unchanged, hashes := n.changedHosts(state)
+
+	for _, h := range changed {
+		result.Changed = append(result.Changed, h.HostName)
+	}
+
+	for _, h := range unchanged {
+		result.Unchanged = append(result.Unchanged, h.HostName)
+	}
+
+	sort.Strings(result.Changed)
+	sort.Strings(result.Unchanged)
+
+	_ = level.Info(n.logger).Log("msg", "reconciling network", "revision", result.Revision, "changed", len(result.Changed), "unchanged", len(result.Unchanged), "dry_run", n.cfg.DryRun)
+
+	if n.cfg.DryRun || len(changed) == 0 {
+		return result, nil
+	}
+
+	err := n.configureHosts(changed, nil)
+	result.Report = n.Report()
+
+	n.recordApplied(state, hashes, result.Report)
+
+	return result, err
+}
+
+// DataRevision returns an identifier of the content of the data directory,
+// which changes when the data changes.  For a git checkout, this is the
+// revision of HEAD, followed for a dirty checkout by a hash of its changes,
+// otherwise it is derived from the names, sizes and modification times of the
+// files in the directory.
+func DataRevision(dir string) (string, error) {
+	if rev := gitRevision(dir); rev != "" {
+		if !strings.HasSuffix(rev, "-dirty") {
+			return rev, nil
+		}
+
+		changes, err := gitChangesHash(dir)
+		if err != nil {
+			return "", err
+		}
+
+		return rev + "-" + changes, nil
+	}
+
+	h := sha256.New()
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		_, err = fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return "files-" + hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// gitChangesHash returns a hash of the uncommitted changes of the git
+// checkout containing dir: the diff of the tracked files from HEAD, and the
+// content of the untracked files of dir.
+func gitChangesHash(dir string) (string, error) {
+	h := sha256.New()
+
+	diff, err := exec.Command("git", "-C", dir, "diff", "HEAD", "--binary").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to diff the data directory")
+	}
+	_, _ = h.Write(diff)
+
+	untracked, err := exec.Command("git", "-C", dir, "ls-files", "--others", "--exclude-standard", "-z").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list the untracked files of the data directory")
+	}
+
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+
+		_, _ = fmt.Fprintf(h, "%s %x\n", name, sha256.Sum256(b))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
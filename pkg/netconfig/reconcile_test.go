@@ -0,0 +1,143 @@
+package netconfig
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadStateMissing(t *testing.T) {
+	state, err := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	require.Empty(t, state.Hosts)
+}
+
+func TestStateSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+
+	state := &State{Hosts: map[string]HostState{
+		"router1.example.com": {Revision: "abc1234", Hash: renderedHash("config")},
+	}}
+	require.NoError(t, state.Save(path))
+
+	loaded, err := LoadState(path)
+	require.NoError(t, err)
+	require.Equal(t, state.Hosts["router1.example.com"].Hash, loaded.Hosts["router1.example.com"].Hash)
+	require.Equal(t, "abc1234", loaded.Hosts["router1.example.com"].Revision)
+}
+
+func TestReconcile(t *testing.T) {
+	device := newTestDevice(t)
+	dir := writeTestData(t, testDataFiles)
+	state := &State{Hosts: map[string]HostState{}}
+
+	// A dry run reports the host which has never been applied.
+	n := newTestNetConfig(t, Config{Data: DataConfig{Directory: dir}, DryRun: true}, device)
+	result, err := n.Reconcile(state)
+	require.NoError(t, err)
+	require.Equal(t, []string{"router1.example.com"}, result.Changed)
+	require.Empty(t, state.Hosts)
+	require.Empty(t, device.Commits())
+
+	n = newTestNetConfig(t, Config{Data: DataConfig{Directory: dir}, Commit: true}, device)
+	result, err = n.Reconcile(state)
+	require.NoError(t, err)
+	require.Equal(t, []string{"router1.example.com"}, result.Changed)
+	require.Len(t, device.Commits(), 1)
+	require.Contains(t, state.Hosts, "router1.example.com")
+
+	// Nothing is pushed while the rendered configuration is unchanged.
+	n = newTestNetConfig(t, Config{Data: DataConfig{Directory: dir}, Commit: true}, device)
+	result, err = n.Reconcile(state)
+	require.NoError(t, err)
+	require.Empty(t, result.Changed)
+	require.Equal(t, []string{"router1.example.com"}, result.Unchanged)
+	require.Len(t, device.Commits(), 1)
+
+	// A change to the data is pushed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data/host/router1.yaml"), []byte("ntp_servers:\n  - 10.0.0.3\n"), 0600))
+
+	n = newTestNetConfig(t, Config{Data: DataConfig{Directory: dir}, Commit: true}, device)
+	result, err = n.Reconcile(state)
+	require.NoError(t, err)
+	require.Equal(t, []string{"router1.example.com"}, result.Changed)
+	require.Len(t, device.Commits(), 2)
+	require.Contains(t, device.Running(), "server 10.0.0.3;")
+}
+
+func TestReconcileFailureNotRecorded(t *testing.T) {
+	device := newTestDevice(t)
+	device.CommitCheck = func(config string) error {
+		return os.ErrPermission
+	}
+
+	state := &State{Hosts: map[string]HostState{}}
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+	_, err := n.Reconcile(state)
+	require.Error(t, err)
+	require.Empty(t, state.Hosts)
+}
+
+func TestDataRevisionFiles(t *testing.T) {
+	dir := writeTestData(t, testDataFiles)
+
+	rev, err := DataRevision(dir)
+	require.NoError(t, err)
+
+	same, err := DataRevision(dir)
+	require.NoError(t, err)
+	require.Equal(t, rev, same)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data/global.yaml"), []byte("ntp_servers: []\n"), 0600))
+
+	changed, err := DataRevision(dir)
+	require.NoError(t, err)
+	require.NotEqual(t, rev, changed)
+}
+
+func TestDataRevisionGit(t *testing.T) {
+	dir := writeTestData(t, testDataFiles)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "data")
+
+	rev, err := DataRevision(dir)
+	require.NoError(t, err)
+	require.NotContains(t, rev, "dirty")
+
+	// Each change to a dirty checkout is a new revision.
+	var revisions []string
+	for _, content := range []string{"ntp_servers: []\n", "ntp_servers: [10.0.0.9]\n"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "data/global.yaml"), []byte(content), 0600))
+
+		dirty, err := DataRevision(dir)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(dirty, rev+"-dirty-"), dirty)
+		revisions = append(revisions, dirty)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data/host/router2.yaml"), []byte("ntp_servers: []\n"), 0600))
+
+	untracked, err := DataRevision(dir)
+	require.NoError(t, err)
+	revisions = append(revisions, untracked)
+
+	same, err := DataRevision(dir)
+	require.NoError(t, err)
+	require.Equal(t, untracked, same)
+
+	require.NotEqual(t, revisions[0], revisions[1])
+	require.NotEqual(t, revisions[1], revisions[2])
+}
@@ -0,0 +1,194 @@
+package netconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/scottdware/go-junos"
+)
+
+// RoutingEngineResult is the outcome of a commit on one routing engine of a
+// dual routing engine or virtual chassis device.
+type RoutingEngineResult struct {
+	Name       string
+	Mastership string `json:",omitempty"`
+	Committed  bool
+	Err        string `json:",omitempty"`
+}
+
+// synchronized returns the commit rpc with synchronize set when the device
+// has more than one routing engine, so that the commit is applied to each of
+// them rather than to the master alone.
+func synchronized(session *junos.Junos, rpc string) string {
+	if session.RoutingEngines < 2 {
+		return rpc
+	}
+
+	return strings.Replace(rpc, "<commit-configuration>", "<commit-configuration><synchronize/>", 1)
+}
+
+// routingEngineResults returns the result of each routing engine found in the
+// commit-results of a commit reply.
+func routingEngineResults(data string) ([]RoutingEngineResult, error) {
+	if !strings.Contains(data, "<commit-results") {
+		return nil, nil
+	}
+
+	var results commitResults
+	err := xml.Unmarshal([]byte(strings.ReplaceAll(data, "\n", "")), &results)
+	if err != nil {
+		return nil, err
+	}
+
+	var engines []RoutingEngineResult
+	for _, re := range results.RoutingEngines {
+		result := RoutingEngineResult{
+			Name:      strings.TrimSpace(re.Name),
+			Committed: re.Success != nil && len(re.Errors) == 0,
+		}
+		for _, e := range re.Errors {
+			result.Err = strings.TrimSpace(e.Message)
+			break
+		}
+		engines = append(engines, result)
+	}
+
+	return engines, nil
+}
+
+// masterAddress is the address of the internal routing engine network which
+// is only configured on the master routing engine.
+const masterAddress = "128.0.0.1"
+
+// connectedToMaster returns whether the session is connected to the master
+// routing engine, from the addresses of the internal interfaces of the
+// routing engine, which include the master address only on the master.  The
+// second value is false when the routing engine has no internal address, and
+// its mastership is unknown.
+func connectedToMaster(session *junos.Junos) (bool, bool, error) {
+	const command = "show interfaces terse routing-instance __juniper_private1__"
+
+	output, err := session.Command(command, "xml")
+	if err != nil {
+		return false, false, errors.Wrapf(err, "failed to run %q", command)
+	}
+
+	var internal, master bool
+	err = decodeElements(output, "ifa-local", func() interface{} { return new(string) }, func(v interface{}) {
+		addr := strings.SplitN(strings.TrimSpace(*v.(*string)), "/", 2)[0]
+		if !strings.HasPrefix(addr, "128.0.0.") {
+			return
+		}
+
+		internal = true
+		if addr == masterAddress {
+			master = true
+		}
+	})
+	if err != nil {
+		return false, false, errors.Wrapf(err, "failed to parse the output of %q", command)
+	}
+
+	return master, internal, nil
+}
+
+// checkMaster refuses a host with more than one routing engine whose session
+// is connected to a routing engine other than the master, since changes
+// committed there are not those of the master.
+func (n *NetConfig) checkMaster(session *junos.Junos, host Host) error {
+	if session.RoutingEngines < 2 {
+		return nil
+	}
+
+	master, known, err := connectedToMaster(session)
+	if err != nil {
+		return err
+	}
+
+	if !known {
+		_ = level.Warn(n.logger).Log("msg", "unable to tell whether the session is connected to the master routing engine", "host", host.HostName)
+		return nil
+	}
+
+	if !master {
+		return fmt.Errorf("refusing to configure %s through a backup routing engine, connect to the master", host.HostName)
+	}
+
+	return nil
+}
+
+// chassisRoutingEngines returns the routing engines of the device and their
+// mastership state.
+func chassisRoutingEngines(session *junos.Junos) ([]routeEngine, error) {
+	output, err := session.Command("show chassis routing-engine", "xml")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run \"show chassis routing-engine\"")
+	}
+
+	var engines []routeEngine
+	err = decodeElements(output, "route-engine", func() interface{} { return &routeEngine{} }, func(v interface{}) {
+		engines = append(engines, *v.(*routeEngine))
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the output of \"show chassis routing-engine\"")
+	}
+
+	return engines, nil
+}
+
+// engineSlot returns the slot of a routing engine named in a commit reply,
+// ie "1" for "re1" or "fpc1".
+func engineSlot(name string) string {
+	return strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz")
+}
+
+// synchronizationFailures matches the commit results with the routing engines
+// of the device, setting their mastership, and returns the master and backup
+// routing engines which did not commit.  Routing engines of a virtual chassis
+// which are neither, ie linecards, are not expected to commit.
+func synchronizationFailures(engines []routeEngine, results []RoutingEngineResult) ([]RoutingEngineResult, []string) {
+	var failures []string
+
+	for _, re := range engines {
+		slot := strings.TrimSpace(re.Slot)
+		mastership := strings.ToLower(strings.TrimSpace(re.MastershipState))
+
+		found := false
+		for i := range results {
+			if engineSlot(results[i].Name) != slot {
+				continue
+			}
+			found = true
+			results[i].Mastership = mastership
+
+			if !results[i].Committed && (mastership == "master" || mastership == "backup") {
+				failures = append(failures, results[i].Name)
+			}
+		}
+
+		if !found && mastership == "backup" {
+			results = append(results, RoutingEngineResult{Name: "re" + slot, Mastership: mastership, Err: "no commit result"})
+			failures = append(failures, "re"+slot)
+		}
+	}
+
+	return results, failures
+}
+
+// checkRoutingEngines records the commit results of each routing engine of a
+// host, and returns an error when its configuration was not synchronized to
+// every master and backup routing engine.
+func (n *NetConfig) checkRoutingEngines(host Host, engines []routeEngine, results []RoutingEngineResult) error {
+	results, failures := synchronizationFailures(engines, results)
+
+	n.updateResult(host, func(r *HostResult) { r.RoutingEngines = results })
+
+	if len(failures) > 0 {
+		return fmt.Errorf("configuration of %s was not synchronized to routing engine(s) %s", host.HostName, strings.Join(failures, ", "))
+	}
+
+	return nil
+}
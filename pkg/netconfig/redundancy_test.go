@@ -0,0 +1,109 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureNetworkRoutingEngines(t *testing.T) {
+	device := newTestDevice(t)
+	device.RoutingEngines = []string{"re0", "re1"}
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+	require.Contains(t, device.Running(), "server 10.0.0.2;")
+
+	// The commit is synchronized to the backup routing engine.
+	require.Equal(t, []RoutingEngineResult{
+		{Name: "re0", Mastership: "master", Committed: true},
+		{Name: "re1", Mastership: "backup", Committed: true},
+	}, n.Report().Hosts[0].RoutingEngines)
+}
+
+func TestConfigureNetworkRoutingEnginesUnsynced(t *testing.T) {
+	device := newTestDevice(t)
+	device.RoutingEngines = []string{"re0", "re1"}
+	device.UnsyncedEngines = []string{"re1"}
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+
+	result := n.Report().Hosts[0]
+	require.Contains(t, result.Err.Error(), "configuration of router1.example.com was not synchronized to routing engine(s) re1")
+	require.True(t, result.Committed)
+	require.Equal(t, []RoutingEngineResult{
+		{Name: "re0", Mastership: "master", Committed: true},
+		{Name: "re1", Mastership: "backup", Err: "commit failed on re1"},
+	}, result.RoutingEngines)
+}
+
+func TestConfigureNetworkBackupRoutingEngine(t *testing.T) {
+	device := newTestDevice(t)
+	device.RoutingEngines = []string{"re0", "re1"}
+	device.ConnectedEngine = "re1"
+
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+
+	require.Error(t, n.ConfigureNetwork())
+	require.Contains(t, n.Report().Hosts[0].Err.Error(), "refusing to configure router1.example.com through a backup routing engine")
+
+	// The host is neither locked nor configured.
+	require.Empty(t, device.Commits())
+	require.NotContains(t, device.RPCs(), "lock-configuration")
+}
+
+func TestConnectedToMaster(t *testing.T) {
+	cases := map[string]struct {
+		connected string
+		output    string
+		master    bool
+		known     bool
+	}{
+		"master":  {connected: "re0", master: true, known: true},
+		"backup":  {connected: "re1", known: true},
+		"unknown": {output: "<interface-information/>"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			device := newTestDevice(t)
+			device.RoutingEngines = []string{"re0", "re1"}
+			device.ConnectedEngine = tc.connected
+			if tc.output != "" {
+				device.SetCommand("show interfaces terse routing-instance __juniper_private1__", tc.output)
+			}
+
+			session, err := device.Dial()
+			require.NoError(t, err)
+			defer session.Close()
+
+			master, known, err := connectedToMaster(session)
+			require.NoError(t, err)
+			require.Equal(t, tc.master, master)
+			require.Equal(t, tc.known, known)
+		})
+	}
+}
+
+func TestSynchronizationFailures(t *testing.T) {
+	// A virtual chassis reports its members by name, and its linecards are
+	// not expected to commit.
+	results, failures := synchronizationFailures([]routeEngine{
+		{Slot: "0", MastershipState: "master"},
+		{Slot: "1", MastershipState: "backup"},
+		{Slot: "2", MastershipState: "linecard"},
+	}, []RoutingEngineResult{
+		{Name: "fpc0", Committed: true},
+		{Name: "fpc2"},
+	})
+
+	require.Equal(t, []string{"re1"}, failures)
+	require.Equal(t, []RoutingEngineResult{
+		{Name: "fpc0", Mastership: "master", Committed: true},
+		{Name: "fpc2", Mastership: "linecard"},
+		{Name: "re1", Mastership: "backup", Err: "no commit result"},
+	}, results)
+}
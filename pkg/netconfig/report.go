@@ -0,0 +1,133 @@
+package netconfig
+
+import (
+	"sort"
+	"time"
+)
+
+// phaseDone is the phase reported once a host has finished.
+const phaseDone = "done"
+
+// HostResult is the outcome of configuring a host during a run.  A host is
+// Skipped when its rendered configuration was unchanged since it was last
+// applied, or by a rollback of a run which did not commit to it, and Drifted when a skipped host was checked and found to differ
+// from it.  Uncommitted holds the changes of other users found in the
+// candidate of a host, which was not configured so as not to discard them.
+// Preflight describes the failed pre-flight checks of a host which was not
+// configured because it is unhealthy.  Checks compares the operational
+// state of the host before and after the commit, and RolledBack is set when
+// the commit was rolled back because a check regressed.
+type HostResult struct {
+	Host           string
+	Diff           string
+	Changed        bool
+	Committed      bool
+	Unconfirmed    bool
+	Skipped        bool
+	Drifted        bool
+	Uncommitted    string
+	Preflight      []string
+	RolledBack     bool
+	Checks         []CheckResult
+	RoutingEngines []RoutingEngineResult
+	Err            error
+}
+
+// RunReport is the outcome of a run which configured the network.
+type RunReport struct {
+	Provenance
+
+	Started  time.Time
+	Finished time.Time
+	Hosts    []HostResult
+}
+
+// Progress reports a host entering a phase of a push, one of "render",
+// "assert", "session", "preflight", "lock", "load", "diff", "commit", "verify"
+// or "done".  Result is set once the host is done.
+type Progress struct {
+	Host   string
+	Phase  string
+	Result *HostResult
+}
+
+// SetProgressFunc sets a function which is called as each host moves through
+// the phases of a push.  The function may be called concurrently.
+func (n *NetConfig) SetProgressFunc(f func(Progress)) {
+	n.progressFunc = f
+}
+
+// Report returns the report of the last run, with the results of the hosts
+// which have finished, ordered by name.
+func (n *NetConfig) Report() RunReport {
+	n.reportMtx.Lock()
+	defer n.reportMtx.Unlock()
+
+	report := n.report
+	report.Hosts = nil
+
+	for _, r := range n.results {
+		report.Hosts = append(report.Hosts, *r)
+	}
+
+	sort.Slice(report.Hosts, func(i, j int) bool {
+		return report.Hosts[i].Host < report.Hosts[j].Host
+	})
+
+	return report
+}
+
+// startReport resets the report at the start of a run.
+func (n *NetConfig) startReport() {
+	n.reportMtx.Lock()
+	defer n.reportMtx.Unlock()
+
+	n.report = RunReport{Provenance: n.provenance, Started: time.Now().UTC()}
+	n.results = map[string]*HostResult{}
+}
+
+// finishReport records the end of a run.
+func (n *NetConfig) finishReport() {
+	n.reportMtx.Lock()
+	defer n.reportMtx.Unlock()
+
+	n.report.Finished = time.Now().UTC()
+}
+
+// updateResult modifies the result of a host.
+func (n *NetConfig) updateResult(host Host, f func(r *HostResult)) {
+	n.reportMtx.Lock()
+	defer n.reportMtx.Unlock()
+
+	if n.results == nil {
+		n.results = map[string]*HostResult{}
+	}
+
+	r, ok := n.results[host.HostName]
+	if !ok {
+		r = &HostResult{Host: host.HostName}
+		n.results[host.HostName] = r
+	}
+
+	f(r)
+}
+
+// progress reports the host entering a phase.
+func (n *NetConfig) progress(host Host, phase string) {
+	if n.progressFunc != nil {
+		n.progressFunc(Progress{Host: host.HostName, Phase: phase})
+	}
+}
+
+// finishHost records the error of a host, if any, and reports it done.
+func (n *NetConfig) finishHost(host Host, err error) {
+	var result HostResult
+	n.updateResult(host, func(r *HostResult) {
+		r.Err = err
+		result = *r
+	})
+
+	if n.progressFunc != nil {
+		n.progressFunc(Progress{Host: host.HostName, Phase: phaseDone, Result: &result})
+	}
+}
@@ -0,0 +1,126 @@
+package netconfig
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRollback is the highest rollback number retained by Junos.
+const maxRollback = 49
+
+// RollbackNetwork rolls back all discovered hosts.  When runID is given, the
+// commit made on each host by that netconfig run is undone, and hosts which
+// were not changed by the run are left alone.  Otherwise each host is rolled
+// back to the given rollback number.  The diff is shown and committed
+// according to the commit options, and the outcome of each host is recorded in
+// the report of the run as a push does.
+func (n *NetConfig) RollbackNetwork(runID string, rollback int) (err error) {
+	if n == nil {
+		return fmt.Errorf("unable to rollback network with nil NetConfig")
+	}
+
+	ctx, span := tracer().Start(context.Background(), "RollbackNetwork",
+		trace.WithAttributes(attrRunID.String(n.provenance.RunID), attrHostCount.Int(len(n.Hosts))))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	metricRuns.Inc()
+	metricHostsSelected.Set(float64(len(n.Hosts)))
+	metricHostsSkipped.Set(0)
+
+	n.startReport()
+
+	defer func() {
+		n.finishReport()
+		metricRunDuration.Set(time.Since(start).Seconds())
+		metricRunTimestamp.SetToCurrentTime()
+	}()
+
+	return n.forEachHost("rollback", func(h Host) error {
+		return n.rollbackHost(ctx, h, runID, rollback)
+	})
+}
+
+// RollbackHost rolls back a single host, as described by RollbackNetwork.
+func (n *NetConfig) RollbackHost(host Host, runID string, rollback int) error {
+	return n.rollbackHost(context.Background(), host, runID, rollback)
+}
+
+func (n *NetConfig) rollbackHost(ctx context.Context, host Host, runID string, rollback int) (err error) {
+	defer func() { n.finishHost(host, err) }()
+
+	session, closeSession, err := n.openSession(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	if runID != "" {
+		records, historyErr := commitHistory(session)
+		if historyErr != nil {
+			closeSession()
+			return recordFailure(host, phaseSession, errors.Wrap(historyErr, "failed to read commit history"))
+		}
+
+		var found bool
+		rollback, found = rollbackForRun(records, runID)
+		if !found {
+			closeSession()
+			_ = level.Info(n.logger).Log("msg", "no commit from run", "host", host.HostName, "run_id", runID)
+			n.updateResult(host, func(r *HostResult) { r.Skipped = true })
+			return nil
+		}
+
+		if rollback > 1 {
+			_ = level.Warn(n.logger).Log("msg", "later commits will also be rolled back", "host", host.HostName, "run_id", runID, "count", rollback-1)
+		}
+	}
+
+	if rollback < 1 || rollback > maxRollback {
+		closeSession()
+		return recordFailure(host, phaseLoad, fmt.Errorf("invalid rollback %d, must be between 1 and %d", rollback, maxRollback))
+	}
+
+	_ = level.Info(n.logger).Log("msg", "rolling back", "host", host.HostName, "rollback", rollback)
+
+	unlockSession, err := n.prepareCandidate(ctx, session, host, closeSession)
+	if err != nil {
+		return err
+	}
+	defer unlockSession()
+
+	_, err = execRPC(session, fmt.Sprintf(rpcLoadRollback, rollback))
+	if err != nil {
+		return recordFailure(host, phaseLoad, fmt.Errorf("unable to load rollback %d on %s: %s", rollback, host.HostName, err))
+	}
+
+	err = n.applyCandidate(ctx, session, host)
+	n.forgetHost(host)
+
+	return err
+}
+
+// rollbackForRun returns the rollback number of the configuration which was
+// active before the first commit made by the given run.  A run may commit more
+// than once, ie a commit confirmed and the commit confirming it, so the oldest
+// of its commits is used.  The second value is false when the run made no
+// commit in the history.
+func rollbackForRun(records []CommitRecord, runID string) (int, bool) {
+	var (
+		rollback int
+		found    bool
+	)
+
+	// The records are ordered from the newest commit.
+	for _, r := range records {
+		if r.Netconfig && r.Provenance.RunID == runID {
+			rollback, found = r.Sequence+1, true
+		}
+	}
+
+	return rollback, found
+}
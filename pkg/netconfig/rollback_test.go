@@ -0,0 +1,39 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackForRun(t *testing.T) {
+	records := []CommitRecord{
+		{Sequence: 0, Comment: "manual fix"},
+		{Sequence: 1, Netconfig: true, Provenance: Provenance{RunID: "run-b"}},
+		{Sequence: 2, Netconfig: true, Provenance: Provenance{RunID: "run-a"}},
+	}
+
+	rollback, ok := rollbackForRun(records, "run-b")
+	require.True(t, ok)
+	require.Equal(t, 2, rollback)
+
+	rollback, ok = rollbackForRun(records, "run-a")
+	require.True(t, ok)
+	require.Equal(t, 3, rollback)
+
+	_, ok = rollbackForRun(records, "run-c")
+	require.False(t, ok)
+}
+
+func TestRollbackForRunConfirmed(t *testing.T) {
+	// A commit confirmed and the commit confirming it share the run.
+	records := []CommitRecord{
+		{Sequence: 0, Netconfig: true, Provenance: Provenance{RunID: "run-a"}},
+		{Sequence: 1, Netconfig: true, Provenance: Provenance{RunID: "run-a"}},
+		{Sequence: 2, Comment: "manual fix"},
+	}
+
+	rollback, ok := rollbackForRun(records, "run-a")
+	require.True(t, ok)
+	require.Equal(t, 2, rollback)
+}
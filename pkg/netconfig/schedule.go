@@ -0,0 +1,134 @@
+package netconfig
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/log/level"
+)
+
+// commitAtWindow is the value of the commit-at option which schedules the
+// commit at the maintenance window of each host.
+const commitAtWindow = "window"
+
+var commitTimeRegexp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} )?\d{2}:\d{2}(:\d{2})?$`)
+
+// ScheduledCommit is a pending scheduled commit on a host.
+type ScheduledCommit struct {
+	Host   string
+	Detail string
+}
+
+// commitTime returns the time at which the commit for the host should be
+// scheduled, or an empty string when the commit should happen immediately.
+func (n *NetConfig) commitTime(host Host) (string, error) {
+	at := n.cfg.CommitAt
+	if at == "" {
+		return "", nil
+	}
+
+	if n.cfg.CommitConfirmed > 0 {
+		return "", fmt.Errorf("commit-at can not be used with commit-confirmed")
+	}
+
+	if at == commitAtWindow {
+		at = host.Data.MaintenanceWindow
+		if at == "" {
+			return "", fmt.Errorf("no maintenance window defined for host %s", host.HostName)
+		}
+	}
+
+	if !commitTimeRegexp.MatchString(at) {
+		return "", fmt.Errorf("invalid commit time %q, expected \"hh:mm[:ss]\" or \"yyyy-mm-dd hh:mm[:ss]\"", at)
+	}
+
+	return at, nil
+}
+
+// ScheduledCommits returns the pending scheduled commits on all discovered
+// hosts.
+func (n *NetConfig) ScheduledCommits() ([]ScheduledCommit, error) {
+	var (
+		mtx     sync.Mutex
+		pending []ScheduledCommit
+	)
+
+	err := n.forEachHost("list scheduled commits", func(h Host) error {
+		details, err := n.hostScheduledCommits(h)
+		if err != nil {
+			return err
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		for _, d := range details {
+			pending = append(pending, ScheduledCommit{Host: h.HostName, Detail: d})
+		}
+
+		return nil
+	})
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Host < pending[j].Host })
+
+	return pending, err
+}
+
+// CancelScheduledCommits clears any pending scheduled commit on all
+// discovered hosts.
+func (n *NetConfig) CancelScheduledCommits() error {
+	return n.forEachHost("cancel scheduled commits", func(h Host) error {
+		err := n.cancelHostScheduledCommit(h)
+		if err != nil {
+			return err
+		}
+
+		_ = level.Info(n.logger).Log("msg", "scheduled commits cleared", "host", h.HostName)
+
+		return nil
+	})
+}
+
+func (n *NetConfig) hostScheduledCommits(host Host) ([]string, error) {
+	session, err := n.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	output, err := session.Command("show system commit", "text")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScheduledCommits(output), nil
+}
+
+func (n *NetConfig) cancelHostScheduledCommit(host Host) error {
+	session, err := n.dial(host)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	_, err = execRPC(session, rpcClearCommit)
+	return err
+}
+
+// parseScheduledCommits returns the pending commit lines from the output of
+// "show system commit".
+func parseScheduledCommits(output string) []string {
+	var pending []string
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "commit requested by") {
+			pending = append(pending, line)
+		}
+	}
+
+	return pending
+}
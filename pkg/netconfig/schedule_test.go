@@ -0,0 +1,57 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func TestCommitTime(t *testing.T) {
+	host := Host{
+		HostName: "router1.example.com",
+		Data:     data.HostData{MaintenanceWindow: "2022-01-10 02:00"},
+	}
+
+	cases := []struct {
+		cfg      Config
+		host     Host
+		expected string
+		err      bool
+	}{
+		{cfg: Config{}, host: host, expected: ""},
+		{cfg: Config{CommitAt: "03:30"}, host: host, expected: "03:30"},
+		{cfg: Config{CommitAt: "2022-01-10 03:30:00"}, host: host, expected: "2022-01-10 03:30:00"},
+		{cfg: Config{CommitAt: "window"}, host: host, expected: "2022-01-10 02:00"},
+		{cfg: Config{CommitAt: "window"}, host: Host{HostName: "router2.example.com"}, err: true},
+		{cfg: Config{CommitAt: "tomorrow"}, host: host, err: true},
+		{cfg: Config{CommitAt: "03:30", CommitConfirmed: 5}, host: host, err: true},
+	}
+
+	for _, tc := range cases {
+		cfg := tc.cfg
+		n := &NetConfig{cfg: &cfg}
+
+		at, err := n.commitTime(tc.host)
+		if tc.err {
+			require.Error(t, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, at)
+	}
+}
+
+func TestParseScheduledCommits(t *testing.T) {
+	output := `
+commit requested by zach via netconf at 2022-01-10 02:00:00 UTC
+0   2022-01-09 14:21:03 UTC by zach via netconf
+    netconfig run=20220109T142100-abcdef operator=zach
+1   2022-01-08 10:00:00 UTC by root via cli
+`
+
+	require.Equal(t, []string{"commit requested by zach via netconf at 2022-01-10 02:00:00 UTC"}, parseScheduledCommits(output))
+	require.Nil(t, parseScheduledCommits("0   2022-01-08 10:00:00 UTC by root via cli\n"))
+}
@@ -0,0 +1,80 @@
+package netconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HostState is the configuration last applied to a host by netconfig.
+type HostState struct {
+	Revision string    `json:"revision,omitempty"`
+	Hash     string    `json:"hash"`
+	Applied  time.Time `json:"applied"`
+}
+
+// State records the configuration last applied to each host, keyed by the
+// host name.  It is stored as JSON in the state file.
+type State struct {
+	Hosts map[string]HostState `json:"hosts"`
+}
+
+// LoadState reads the state file at path.  An empty State is returned when the
+// file does not exist.
+func LoadState(path string) (*State, error) {
+	state := &State{Hosts: map[string]HostState{}}
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, errors.Wrap(err, "failed to read state file")
+	}
+
+	err = json.Unmarshal(buf, state)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse state file %s", path)
+	}
+
+	if state.Hosts == nil {
+		state.Hosts = map[string]HostState{}
+	}
+
+	return state, nil
+}
+
+// Save writes the state file to path, replacing it atomically so that an
+// interrupted write does not lose the previous state.
+func (s *State) Save(path string) error {
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return errors.Wrap(err, "failed to create state directory")
+	}
+
+	tmp := path + ".tmp"
+
+	err = os.WriteFile(tmp, append(buf, '\n'), 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write state file")
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// renderedHash returns the hash by which the rendered configuration of a host
+// is compared to the configuration last applied.
+func renderedHash(rendered string) string {
+	sum := sha256.Sum256([]byte(rendered))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,179 @@
+package netconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// statement is a single leaf statement of a configuration in the curly brace
+// format, as the words of its hierarchy followed by the words of the leaf.
+type statement []string
+
+func (s statement) String() string {
+	return strings.Join(s, " ")
+}
+
+// hasPrefix returns true when the leading words of the statement match the
+// patterns, in which "*" matches any characters including "/", and "?" any
+// single character.
+func (s statement) hasPrefix(patterns []string) bool {
+	if len(patterns) > len(s) {
+		return false
+	}
+
+	for i, p := range patterns {
+		if !matchWord(p, s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchWord returns true when the word matches the wildcard pattern.  Unlike
+// path.Match, "*" also matches "/", so that "ge-*" matches "ge-0/0/0".
+func matchWord(pattern, word string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == word
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	ok, _ := regexp.MatchString("^"+expr+"$", word)
+
+	return ok
+}
+
+// parseStatements parses a configuration in the curly brace format, as
+// rendered by the templates, into its leaf statements.  An empty container,
+// ie "foo { }", is a statement of its own.  Load directives such as "replace:"
+// are ignored.
+func parseStatements(text string) ([]statement, error) {
+	var (
+		statements []statement
+		stack      []int
+		counts     []int
+		words      []string
+	)
+
+	tokens, err := tokenizeConfig(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var hierarchy []string
+	for _, t := range tokens {
+		switch t {
+		case "{":
+			if len(words) == 0 {
+				return nil, fmt.Errorf("syntax error, unexpected '{'")
+			}
+			stack = append(stack, len(words))
+			counts = append(counts, len(statements))
+			hierarchy = append(hierarchy, words...)
+			words = nil
+		case "}":
+			if len(words) > 0 {
+				return nil, fmt.Errorf("syntax error, expecting ';' after %q", strings.Join(words, " "))
+			}
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("syntax error, unexpected '}'")
+			}
+			if counts[len(counts)-1] == len(statements) {
+				statements = append(statements, append(statement{}, hierarchy...))
+			}
+			hierarchy = hierarchy[:len(hierarchy)-stack[len(stack)-1]]
+			stack = stack[:len(stack)-1]
+			counts = counts[:len(counts)-1]
+		case ";":
+			if len(words) == 0 {
+				continue
+			}
+			s := append(append(statement{}, hierarchy...), words...)
+			statements = append(statements, s)
+			words = nil
+		case "replace:", "merge:", "protect:", "inactive:", "active:":
+			continue
+		default:
+			words = append(words, t)
+		}
+	}
+
+	if len(words) > 0 {
+		return nil, fmt.Errorf("syntax error, expecting ';' or '{' after %q", strings.Join(words, " "))
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("syntax error, missing '}'")
+	}
+
+	return statements, nil
+}
+
+// tokenizeConfig splits the curly brace format into words, braces and
+// semicolons, removing comments.  Quoted strings, in which a backslash escapes
+// the next character, and bracketed lists are kept as single words.
+func tokenizeConfig(text string) ([]string, error) {
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == '#' && word.Len() == 0:
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(text) && text[i+1] == '*':
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error, unterminated comment")
+			}
+			i += end + 3
+		case c == '"':
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("syntax error, unterminated string")
+			}
+			word.WriteString(text[i : end+1])
+			i = end
+		case c == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("syntax error, unterminated list")
+			}
+			flush()
+			tokens = append(tokens, "[ "+strings.Join(strings.Fields(text[i+1:i+end]), " ")+" ]")
+			i += end
+		case c == '{' || c == '}' || c == ';':
+			flush()
+			tokens = append(tokens, string(c))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+
+	return tokens, nil
+}
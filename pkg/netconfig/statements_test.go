@@ -0,0 +1,73 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatements(t *testing.T) {
+	cases := map[string]struct {
+		config     string
+		statements []string
+		err        string
+	}{
+		"leaves": {
+			config:     "system { host-name router1; services { ssh; } }",
+			statements: []string{"system host-name router1", "system services ssh"},
+		},
+		"comments and directives": {
+			config:     "/* managed */\n# comment\ninterfaces {\n    replace:\n    lo0 { unit 0; }\n}",
+			statements: []string{"interfaces lo0 unit 0"},
+		},
+		"list": {
+			config:     "policy-options { community c1 members [ 65000:1  65000:2 ]; }",
+			statements: []string{"policy-options community c1 members [ 65000:1 65000:2 ]"},
+		},
+		"quoted": {
+			config:     `system { login { message "welcome; { to } router1"; } }`,
+			statements: []string{`system login message "welcome; { to } router1"`},
+		},
+		"escaped quote": {
+			config:     `interfaces { ge-0/0/0 { description "link to \"core1\" {a}"; } }`,
+			statements: []string{`interfaces ge-0/0/0 description "link to \"core1\" {a}"`},
+		},
+		"escaped backslash": {
+			config:     `system { login { message "C:\\"; } host-name router1; }`,
+			statements: []string{`system login message "C:\\"`, "system host-name router1"},
+		},
+		"empty container": {
+			config:     "protocols { lldp { } mstp { interface ge-0/0/0; } } snmp { }",
+			statements: []string{"protocols lldp", "protocols mstp interface ge-0/0/0", "snmp"},
+		},
+		"unterminated string": {
+			config: `system { host-name "router1\"; }`,
+			err:    "syntax error, unterminated string",
+		},
+		"missing semicolon": {
+			config: "system { host-name router1 }",
+			err:    `syntax error, expecting ';' after "host-name router1"`,
+		},
+		"missing brace": {
+			config: "system { host-name router1;",
+			err:    "syntax error, missing '}'",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			statements, err := parseStatements(tc.config)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var got []string
+			for _, s := range statements {
+				got = append(got, s.String())
+			}
+			require.Equal(t, tc.statements, got)
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xaque208/znet/modules/inventory"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// Types of link.
+const (
+	LinkP2P    = "p2p"
+	LinkTrunk  = "trunk"
+	LinkAccess = "access"
+)
+
+// defaultTopologyFile is the file of the data directory from which links are
+// loaded when none is configured.
+const defaultTopologyFile = "topology.yaml"
+
+// loadTopology returns the links of the topology file of the data directory.
+// No links are returned when the default file does not exist.
+func loadTopology(dir string, d data.Data) ([]data.Link, error) {
+	file := d.TopologyFile
+	if file == "" {
+		file = defaultTopologyFile
+	}
+
+	path := filepath.Join(dir, file)
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && d.TopologyFile == "" {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read topology")
+	}
+
+	var topology data.Topology
+	err = yaml.UnmarshalStrict(buf, &topology)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse topology file %s", path)
+	}
+
+	return topology.Links, nil
+}
+
+// splitLinkEnd returns the host and interface names of an end of a link.
+func splitLinkEnd(end string) (string, string, error) {
+	parts := strings.SplitN(end, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("link end %q is not of the form \"host:interface\"", end)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// linkType returns the type of the link, which when not given is "p2p" for
+// links with a subnet or pool, and "trunk" for links with VLANs.
+func linkType(l data.Link) string {
+	switch {
+	case l.Type != "":
+		return l.Type
+	case l.Subnet != "" || l.Subnet6 != "" || l.Pool != "":
+		return LinkP2P
+	case len(l.VLANs) > 0:
+		return LinkTrunk
+	}
+
+	return ""
+}
+
+// linkAddress returns the address of an end of a point-to-point subnet.  The
+// ends of a /31 or /127 are its two addresses, while those of larger subnets
+// skip the first.
+func linkAddress(subnet string, end int) (string, error) {
+	_, ipnet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return "", err
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 1 {
+		end++
+	}
+
+	return prefixAddress(subnet, end)
+}
+
+// applyTopology generates the interfaces of the hosts at each end of the links.
+// The description, addressing and switching of an interface already in the
+// data of a host are replaced by those of the link.  Each end must name a host
+// of the inventory, so that a misspelled host is not silently left out, while
+// the ends of hosts which are not selected are skipped.
+func (n *NetConfig) applyTopology(links []data.Link, hosts []inventory.NetworkHost) error {
+	known := map[string]bool{}
+	for i := range hosts {
+		known[hosts[i].Name] = true
+		known[hosts[i].Name+"."+hosts[i].Domain] = true
+	}
+
+	for _, l := range links {
+		err := n.applyLink(l, known)
+		if err != nil {
+			return errors.Wrapf(err, "invalid link %s %s", l.A, l.B)
+		}
+	}
+
+	return nil
+}
+
+func (n *NetConfig) applyLink(l data.Link, known map[string]bool) error {
+	ends := [2]string{l.A, l.B}
+
+	var hosts, ifaces [2]string
+	for i, end := range ends {
+		h, iface, err := splitLinkEnd(end)
+		if err != nil {
+			return err
+		}
+		if !known[h] {
+			return fmt.Errorf("unknown host %q", h)
+		}
+		hosts[i], ifaces[i] = h, iface
+	}
+
+	typ := linkType(l)
+
+	subnet, subnet6 := l.Subnet, l.Subnet6
+	if typ == LinkP2P && l.Pool != "" && subnet == "" && subnet6 == "" {
+		allocated, err := n.Allocate(l.Pool, l.A+" "+l.B)
+		if err != nil {
+			return err
+		}
+
+		ip, _, err := net.ParseCIDR(allocated)
+		if err != nil {
+			return err
+		}
+
+		if ip.To4() != nil {
+			subnet = allocated
+		} else {
+			subnet6 = allocated
+		}
+	}
+
+	switch typ {
+	case "", LinkP2P, LinkTrunk, LinkAccess:
+	default:
+		return fmt.Errorf("unknown link type %q", typ)
+	}
+
+	for i := range ends {
+		peer := 1 - i
+
+		host := n.topologyHost(hosts[i])
+		if host == nil {
+			continue
+		}
+
+		description := l.Description
+		if description == "" {
+			description = ends[peer]
+		}
+
+		iface := linkInterface{
+			description: description,
+			mtu:         l.MTU,
+		}
+
+		switch typ {
+		case LinkP2P:
+			unit := data.InetUnit{MTU: l.MTU}
+
+			if subnet != "" {
+				addr, err := linkAddress(subnet, i)
+				if err != nil {
+					return err
+				}
+				unit.Inet = []string{addr}
+			}
+
+			if subnet6 != "" {
+				addr, err := linkAddress(subnet6, i)
+				if err != nil {
+					return err
+				}
+				unit.Inet6 = []string{addr}
+			}
+
+			iface.units = []data.InetUnit{unit}
+		case LinkTrunk, LinkAccess:
+			iface.switching = &data.EthernetSwitching{Mode: typ, VLANs: l.VLANs}
+			iface.nativeVlanID = l.NativeVlanID
+		}
+
+		iface.apply(&host.Data, ifaces[i])
+	}
+
+	return nil
+}
+
+// topologyHost returns the selected host with the short or fully qualified
+// name, or nil.
+func (n *NetConfig) topologyHost(name string) *Host {
+	for i := range n.Hosts {
+		if n.Hosts[i].HostName == name || n.Hosts[i].NetworkHost.Name == name {
+			return &n.Hosts[i]
+		}
+	}
+
+	return nil
+}
+
+// linkInterface is the interface data generated for an end of a link.
+type linkInterface struct {
+	description  string
+	mtu          int
+	units        []data.InetUnit
+	switching    *data.EthernetSwitching
+	nativeVlanID int
+}
+
+// apply sets the interface of the given name in the data, adding an
+// aggregated ethernet interface for "ae" names, and an ethernet interface
+// otherwise.  The slices of the data may be shared with the cache, and are
+// copied before being modified.
+func (li linkInterface) apply(d *data.HostData, name string) {
+	if strings.HasPrefix(name, "ae") {
+		ifaces := append([]data.AEInterface(nil), d.AEInterfaces...)
+
+		i := len(ifaces)
+		for j := range ifaces {
+			if ifaces[j].Name == name {
+				i = j
+				break
+			}
+		}
+		if i == len(ifaces) {
+			ifaces = append(ifaces, data.AEInterface{Name: name})
+		}
+
+		ifaces[i].Description = li.description
+		if li.mtu != 0 {
+			ifaces[i].MTU = li.mtu
+		}
+		if li.units != nil {
+			ifaces[i].Units = li.units
+		}
+		if li.switching != nil {
+			ifaces[i].EthernetSwitching = *li.switching
+			ifaces[i].NativeVlanID = li.nativeVlanID
+		}
+
+		d.AEInterfaces = ifaces
+		return
+	}
+
+	ifaces := append([]data.EthernetInterface(nil), d.EthernetInterfaces...)
+
+	i := len(ifaces)
+	for j := range ifaces {
+		if ifaces[j].Name == name {
+			i = j
+			break
+		}
+	}
+	if i == len(ifaces) {
+		ifaces = append(ifaces, data.EthernetInterface{Name: name})
+	}
+
+	ifaces[i].Description = li.description
+	if li.mtu != 0 {
+		ifaces[i].MTU = li.mtu
+	}
+	if li.units != nil {
+		ifaces[i].Units = li.units
+	}
+	if li.switching != nil {
+		ifaces[i].EthernetSwitching = *li.switching
+		ifaces[i].NativeVlanID = li.nativeVlanID
+	}
+
+	d.EthernetInterfaces = ifaces
+}
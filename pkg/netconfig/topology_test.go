@@ -0,0 +1,153 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+func TestTopology(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+hierarchy:
+  - "host/{{ .NetworkHost.Name }}.yaml"
+pools:
+  - name: p2p
+    type: prefix
+    range: 10.255.0.0/24
+    size: 31
+`,
+		"data/host/router1.yaml": `
+eth_interfaces:
+  - name: ge-0/0/1
+    description: old
+    ethernet_options: ["802.3ad ae0"]
+`,
+		"topology.yaml": `
+links:
+  - a: router1:ge-0/0/0
+    b: router2.example.com:ge-0/0/0
+    subnet: 10.254.0.0/31
+    subnet6: 2001:db8::/64
+    mtu: 9192
+  - a: router1:ge-0/0/1
+    b: router2:ge-0/0/1
+    pool: p2p
+  - a: router1:ae0
+    b: router2:ae0
+    vlans: [users, lab]
+    native_vlan_id: 10
+  - a: router1:ge-0/0/2
+    b: switch9:ge-0/0/0
+    type: access
+    vlans: [users]
+`,
+	})
+
+	n, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}}, []inventory.NetworkHost{
+		{Name: "router1", Domain: "example.com", Platform: "junos"},
+		{Name: "router2", Domain: "example.com", Platform: "junos"},
+		{Name: "switch9", Domain: "example.com", Platform: "ios"},
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	router1, err := n.Host("router1")
+	require.NoError(t, err)
+
+	require.Equal(t, []data.EthernetInterface{
+		{
+			Name:            "ge-0/0/1",
+			Description:     "router2:ge-0/0/1",
+			EthernetOptions: []string{"802.3ad ae0"},
+			Units:           []data.InetUnit{{Inet: []string{"10.255.0.0/31"}}},
+		},
+		{
+			Name:        "ge-0/0/0",
+			Description: "router2.example.com:ge-0/0/0",
+			MTU:         9192,
+			Units:       []data.InetUnit{{Inet: []string{"10.254.0.0/31"}, Inet6: []string{"2001:db8::1/64"}, MTU: 9192}},
+		},
+		{
+			Name:              "ge-0/0/2",
+			Description:       "switch9:ge-0/0/0",
+			EthernetSwitching: data.EthernetSwitching{Mode: "access", VLANs: []string{"users"}},
+		},
+	}, router1.Data.EthernetInterfaces)
+
+	require.Len(t, router1.Data.AEInterfaces, 1)
+	require.Equal(t, "router2:ae0", router1.Data.AEInterfaces[0].Description)
+	require.Equal(t, data.EthernetSwitching{Mode: "trunk", VLANs: []string{"users", "lab"}}, router1.Data.AEInterfaces[0].EthernetSwitching)
+	require.Equal(t, 10, router1.Data.AEInterfaces[0].NativeVlanID)
+
+	router2, err := n.Host("router2")
+	require.NoError(t, err)
+
+	require.Equal(t, []data.EthernetInterface{
+		{
+			Name:        "ge-0/0/0",
+			Description: "router1:ge-0/0/0",
+			MTU:         9192,
+			Units:       []data.InetUnit{{Inet: []string{"10.254.0.1/31"}, Inet6: []string{"2001:db8::2/64"}, MTU: 9192}},
+		},
+		{
+			Name:        "ge-0/0/1",
+			Description: "router1:ge-0/0/1",
+			Units:       []data.InetUnit{{Inet: []string{"10.255.0.1/31"}}},
+		},
+	}, router2.Data.EthernetInterfaces)
+
+	require.Empty(t, n.CheckConsistency())
+}
+
+func TestTopologyInvalidLink(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+links:
+  - a: router1
+    b: router2:ge-0/0/0
+`,
+	})
+
+	_, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}}, []inventory.NetworkHost{
+		{Name: "router1", Domain: "example.com", Platform: "junos"},
+	}, log.NewNopLogger())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `link end "router1" is not of the form "host:interface"`)
+}
+
+func TestTopologyUnknownHost(t *testing.T) {
+	dir := writeTestData(t, map[string]string{
+		"data.yaml": `
+links:
+  - a: router1:ge-0/0/0
+    b: ruoter2:ge-0/0/0
+`,
+	})
+
+	hosts := []inventory.NetworkHost{
+		{Name: "router1", Domain: "example.com", Platform: "junos"},
+		{Name: "router2", Domain: "example.com", Platform: "junos"},
+	}
+
+	_, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}}, hosts, log.NewNopLogger())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `unknown host "ruoter2"`)
+
+	// A host of the inventory which is not selected is skipped.
+	dir = writeTestData(t, map[string]string{
+		"data.yaml": `
+links:
+  - a: router1:ge-0/0/0
+    b: router2:ge-0/0/0
+`,
+	})
+
+	n, err := NewWithHosts(Config{Data: DataConfig{Directory: dir}, Limit: "router1"}, hosts, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, n.Hosts, 1)
+	require.Equal(t, "router2:ge-0/0/0", n.Hosts[0].Data.EthernetInterfaces[0].Description)
+}
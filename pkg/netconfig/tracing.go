@@ -0,0 +1,48 @@
+package netconfig
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer returns the tracer of the package from the current global provider,
+// so that a provider replaced after the first span is used.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/xaque208/netconfig/pkg/netconfig")
+}
+
+// Attribute keys of the spans.
+const (
+	attrHost          = attribute.Key("host")
+	attrRole          = attribute.Key("role")
+	attrTemplate      = attribute.Key("template")
+	attrFile          = attribute.Key("file")
+	attrRunID         = attribute.Key("run_id")
+	attrHostCount     = attribute.Key("host_count")
+	attrDiffAdded     = attribute.Key("diff.added")
+	attrDiffRemoved   = attribute.Key("diff.removed")
+	attrCommitAt      = attribute.Key("commit.at")
+	attrCommitConfirm = attribute.Key("commit.confirm")
+)
+
+// hostAttributes returns the attributes identifying a host on a span.
+func hostAttributes(host Host) trace.SpanStartOption {
+	attrs := []attribute.KeyValue{attrHost.String(host.HostName)}
+	if host.NetworkHost != nil {
+		attrs = append(attrs, attrRole.String(host.NetworkHost.Role))
+	}
+
+	return trace.WithAttributes(attrs...)
+}
+
+// endSpan records the error, if any, on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
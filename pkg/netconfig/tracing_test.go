@@ -0,0 +1,65 @@
+package netconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConfigureNetworkSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(provider) })
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	device := newTestDevice(t)
+	n := newTestNetConfig(t, Config{Commit: true}, device)
+
+	require.NoError(t, n.ConfigureNetwork())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+
+	for _, name := range []string{
+		"ListNetworkHosts", "LoadHostData", "DataForHost", "LoadHostDataFile",
+		"ConfigureNetwork", "ConfigureNetworkHost", "RenderHost", "RenderTemplate",
+		"OpenSession", "Lock", "LoadConfiguration", "Diff", "Commit", "Unlock",
+	} {
+		require.Contains(t, spans, name)
+	}
+
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := map[attribute.Key]attribute.Value{}
+		for _, kv := range s.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	host := spans["ConfigureNetworkHost"]
+	require.Equal(t, "router1.example.com", attrs(host)[attrHost].AsString())
+	require.Equal(t, "core", attrs(host)[attrRole].AsString())
+	require.Equal(t, spans["ConfigureNetwork"].SpanContext().SpanID(), host.Parent().SpanID())
+
+	require.Contains(t, attrs(spans["RenderTemplate"])[attrTemplate].AsString(), "system.tmpl")
+	require.Equal(t, int64(1), attrs(spans["Diff"])[attrDiffAdded].AsInt64())
+	require.Equal(t, spans["ConfigureNetworkHost"].SpanContext().SpanID(), spans["Commit"].Parent().SpanID())
+
+	// Errors are recorded on the span of the failing phase.
+	require.NoError(t, device.SetCandidate("system { host-name other; }"))
+	require.Error(t, n.ConfigureNetwork())
+
+	var lockErrors int
+	for _, s := range recorder.Ended() {
+		if s.Name() == "Lock" && len(s.Events()) > 0 {
+			lockErrors++
+		}
+	}
+	require.Equal(t, 1, lockErrors)
+}
@@ -0,0 +1,329 @@
+package netconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/scottdware/go-junos"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+// Types of operational check.
+const (
+	CheckBGP        = "bgp"
+	CheckInterfaces = "interfaces"
+	CheckLACP       = "lacp"
+	CheckRoutes     = "routes"
+)
+
+// defaultRouteTable is the table counted by route checks when none is given.
+const defaultRouteTable = "inet.0"
+
+// checkCommands are the operational commands which capture the state of each
+// type of check.
+var checkCommands = map[string]string{
+	CheckBGP:        "show bgp summary",
+	CheckInterfaces: "show interfaces terse",
+	CheckLACP:       "show lacp interfaces",
+	CheckRoutes:     "show route summary",
+}
+
+// CheckResult compares the state captured by an operational check before and
+// after a change.  Before and After count the established peers, interfaces
+// up, active LACP members or active routes, and Regressions describes what was
+// lost.
+type CheckResult struct {
+	Name        string
+	Type        string
+	Before      int
+	After       int
+	Regressions []string
+}
+
+// checkState is the state captured by a check: the peers, interfaces or
+// members which are up, or the number of routes.
+type checkState struct {
+	items []string
+	count int
+}
+
+type bgpSummary struct {
+	Peers []struct {
+		Address string `xml:"peer-address"`
+		State   string `xml:"peer-state"`
+	} `xml:"bgp-peer"`
+}
+
+type interfacesTerse struct {
+	Interfaces []struct {
+		Name        string `xml:"name"`
+		AdminStatus string `xml:"admin-status"`
+		OperStatus  string `xml:"oper-status"`
+	} `xml:"physical-interface"`
+}
+
+type lacpInterfaces struct {
+	Aggregates []struct {
+		Name    string `xml:"lag-lacp-header>aggregate-name"`
+		Members []struct {
+			Name     string `xml:"name"`
+			MuxState string `xml:"lacp-mux-state"`
+		} `xml:"lag-lacp-protocol"`
+	} `xml:"lacp-interface-information"`
+}
+
+type routeSummary struct {
+	Tables []struct {
+		Name   string `xml:"table-name"`
+		Active string `xml:"active-route-count"`
+	} `xml:"route-table"`
+}
+
+// hostChecks returns the operational checks which apply to the host.
+func (n *NetConfig) hostChecks(host Host) []data.Check {
+	var checks []data.Check
+	for _, c := range n.Data.Checks {
+		if hostMatches(c.Roles, c.Hosts, host) {
+			checks = append(checks, c)
+		}
+	}
+
+	return checks
+}
+
+// captureChecks captures the state of each of the checks on the device.
+func captureChecks(session *junos.Junos, checks []data.Check) ([]checkState, error) {
+	states := make([]checkState, len(checks))
+
+	for i, c := range checks {
+		command, ok := checkCommands[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q of check %s", c.Type, c.Name)
+		}
+
+		output, err := session.Command(command, "xml")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to capture check %s", c.Name)
+		}
+
+		states[i], err = parseCheckState(c, output)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the output of check %s", c.Name)
+		}
+	}
+
+	return states, nil
+}
+
+// parseCheckState parses the xml output of the command of the check.
+func parseCheckState(c data.Check, output string) (checkState, error) {
+	var state checkState
+
+	switch c.Type {
+	case CheckBGP:
+		var summary bgpSummary
+		if err := xml.Unmarshal([]byte(output), &summary); err != nil {
+			return state, err
+		}
+
+		for _, p := range summary.Peers {
+			if strings.EqualFold(strings.TrimSpace(p.State), "Established") {
+				state.items = append(state.items, strings.TrimSpace(p.Address))
+			}
+		}
+	case CheckInterfaces:
+		var terse interfacesTerse
+		if err := xml.Unmarshal([]byte(output), &terse); err != nil {
+			return state, err
+		}
+
+		for _, i := range terse.Interfaces {
+			if strings.TrimSpace(i.AdminStatus) == "up" && strings.TrimSpace(i.OperStatus) == "up" {
+				state.items = append(state.items, strings.TrimSpace(i.Name))
+			}
+		}
+	case CheckLACP:
+		var lacp lacpInterfaces
+		if err := xml.Unmarshal([]byte(output), &lacp); err != nil {
+			return state, err
+		}
+
+		for _, a := range lacp.Aggregates {
+			for _, m := range a.Members {
+				if strings.EqualFold(strings.TrimSpace(m.MuxState), "Collecting distributing") {
+					state.items = append(state.items, strings.TrimSpace(a.Name)+" "+strings.TrimSpace(m.Name))
+				}
+			}
+		}
+	case CheckRoutes:
+		var summary routeSummary
+		if err := xml.Unmarshal([]byte(output), &summary); err != nil {
+			return state, err
+		}
+
+		table := c.Table
+		if table == "" {
+			table = defaultRouteTable
+		}
+
+		for _, t := range summary.Tables {
+			if strings.TrimSpace(t.Name) == table {
+				count, err := strconv.Atoi(strings.TrimSpace(t.Active))
+				if err != nil {
+					return state, err
+				}
+				state.count = count
+			}
+		}
+
+		return state, nil
+	}
+
+	state.count = len(state.items)
+
+	return state, nil
+}
+
+// compareCheck compares the state of a check before and after a change.
+func compareCheck(c data.Check, before, after checkState) CheckResult {
+	result := CheckResult{Name: c.Name, Type: c.Type, Before: before.count, After: after.count}
+
+	if c.Type == CheckRoutes {
+		if after.count < before.count-c.Tolerance {
+			table := c.Table
+			if table == "" {
+				table = defaultRouteTable
+			}
+			result.Regressions = append(result.Regressions, fmt.Sprintf("active routes in %s dropped from %d to %d", table, before.count, after.count))
+		}
+
+		return result
+	}
+
+	state := map[string]string{
+		CheckBGP:        "established",
+		CheckInterfaces: "up",
+		CheckLACP:       "active",
+	}[c.Type]
+
+	for _, item := range before.items {
+		if !containsString(after.items, item) {
+			result.Regressions = append(result.Regressions, fmt.Sprintf("%s no longer %s", item, state))
+		}
+	}
+
+	return result
+}
+
+// confirmMargin is the time left before the device rolls back a commit
+// confirmed, in which netconfig rolls back or confirms it after its checks.
+const confirmMargin = time.Minute
+
+// verifyTimeout returns the time to wait for the checks of a change committed
+// with the given confirm minutes to recover.  The verify timeout is capped to
+// end a margin before the device would roll the change back on its own.
+func (n *NetConfig) verifyTimeout(confirm int) time.Duration {
+	timeout := n.cfg.Verify.Timeout
+
+	if confirm > 0 {
+		if limit := time.Duration(confirm)*time.Minute - confirmMargin; timeout > limit {
+			timeout = limit
+		}
+	}
+
+	if timeout < 0 {
+		return 0
+	}
+
+	return timeout
+}
+
+// verifyChecks captures the state of the checks after a change was committed
+// and compares it with the state before, until no check has regressed or the
+// verify timeout has passed.  The comparison is recorded in the report of the
+// host, and an error describing the regressions is returned.
+func (n *NetConfig) verifyChecks(session *junos.Junos, host Host, checks []data.Check, before []checkState, confirm int) error {
+	timeout := n.verifyTimeout(confirm)
+	if timeout < n.cfg.Verify.Timeout {
+		_ = level.Warn(n.logger).Log("msg", "verify timeout capped by commit confirmed", "host", host.HostName, "timeout", timeout, "confirm", confirm)
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		after, err := captureChecks(session, checks)
+		if err != nil {
+			return err
+		}
+
+		var (
+			results     []CheckResult
+			regressions []string
+		)
+		for i, c := range checks {
+			r := compareCheck(c, before[i], after[i])
+			results = append(results, r)
+
+			for _, reg := range r.Regressions {
+				regressions = append(regressions, fmt.Sprintf("%s: %s", c.Name, reg))
+			}
+		}
+
+		n.updateResult(host, func(r *HostResult) { r.Checks = results })
+
+		if len(regressions) == 0 {
+			return nil
+		}
+
+		if !time.Now().Add(n.cfg.Verify.Interval).Before(deadline) {
+			return fmt.Errorf("%d check(s) regressed on %s: %s", len(regressions), host.HostName, strings.Join(regressions, "; "))
+		}
+
+		_ = level.Info(n.logger).Log("msg", "waiting for checks to recover", "host", host.HostName, "regressions", strings.Join(regressions, "; "))
+
+		time.Sleep(n.cfg.Verify.Interval)
+	}
+}
+
+// confirmPending returns an error when the commit confirmed made by this run
+// is no longer the newest commit of the host, as when the device rolled it
+// back before it was verified, so that neither a rollback nor a confirmation
+// is applied to another configuration.
+func (n *NetConfig) confirmPending(session *junos.Junos, host Host) error {
+	records, err := commitHistory(session)
+	if err != nil {
+		return errors.Wrap(err, "failed to read commit history")
+	}
+
+	if len(records) > 0 && records[0].Netconfig && records[0].Provenance.RunID == n.provenance.RunID {
+		return nil
+	}
+
+	return fmt.Errorf("the commit confirmed on %s is no longer active, the device rolled it back or another commit replaced it", host.HostName)
+}
+
+// rollbackConfirmed rolls back a change committed with commit confirmed,
+// without waiting for the device to roll it back.
+func (n *NetConfig) rollbackConfirmed(session *junos.Junos, host Host) error {
+	_ = level.Warn(n.logger).Log("msg", "rolling back change", "host", host.HostName)
+
+	_, err := execRPC(session, fmt.Sprintf(rpcLoadRollback, 1))
+	if err != nil {
+		return err
+	}
+
+	err = commit(session, n.provenance.Comment(), 0)
+	if err != nil {
+		return err
+	}
+
+	n.updateResult(host, func(r *HostResult) { r.RolledBack = true })
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package netconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xaque208/netconfig/pkg/netconfig/data"
+)
+
+const testChecksData = `
+template_dir: templates
+template_paths:
+  - "platform/{{ .NetworkHost.Platform }}"
+hierarchy:
+  - "global.yaml"
+  - "host/{{ .NetworkHost.Name }}.yaml"
+checks:
+  - name: peers
+    type: bgp
+    roles: [core]
+  - name: uplinks
+    type: interfaces
+  - name: edge
+    type: lacp
+    roles: [edge]
+`
+
+// testBGPSummary returns the bgp summary of a device whose second peer is
+// only established until a ntp server is configured.
+func testBGPSummary(running string) string {
+	state := "Established"
+	if strings.Contains(running, "server 10.0.0.2;") {
+		state = "Active"
+	}
+
+	return `<bgp-information>
+<bgp-peer><peer-address>10.255.0.1</peer-address><peer-state>Established</peer-state></bgp-peer>
+<bgp-peer><peer-address>10.255.0.3</peer-address><peer-state>` + state + `</peer-state></bgp-peer>
+</bgp-information>`
+}
+
+const testInterfacesTerse = `<interface-information>
+<physical-interface>
+<name>ge-0/0/0</name>
+<admin-status>up</admin-status>
+<oper-status>up</oper-status>
+</physical-interface>
+</interface-information>`
+
+func newTestChecksNetConfig(t *testing.T, cfg Config) (*NetConfig, func() bool) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data.yaml"] = testChecksData
+	cfg.Data.Directory = writeTestData(t, files)
+
+	device := newTestDevice(t)
+	device.SetCommandFunc("show bgp summary", testBGPSummary)
+	device.SetCommand("show interfaces terse", testInterfacesTerse)
+
+	n := newTestNetConfig(t, cfg, device)
+
+	return n, func() bool { return strings.Contains(device.Running(), "server 10.0.0.2;") && !device.ConfirmPending() }
+}
+
+func TestConfigureNetworkChecksRollback(t *testing.T) {
+	n, committed := newTestChecksNetConfig(t, Config{Commit: true, CommitConfirmed: 5})
+
+	err := n.ConfigureNetwork()
+	require.Error(t, err)
+	require.Contains(t, n.Report().Hosts[0].Err.Error(), "1 check(s) regressed on router1.example.com: peers: 10.255.0.3 no longer established, the change was rolled back")
+
+	require.False(t, committed())
+
+	result := n.Report().Hosts[0]
+	require.True(t, result.Committed)
+	require.True(t, result.RolledBack)
+	require.Equal(t, []CheckResult{
+		{Name: "peers", Type: CheckBGP, Before: 2, After: 1, Regressions: []string{"10.255.0.3 no longer established"}},
+		{Name: "uplinks", Type: CheckInterfaces, Before: 1, After: 1},
+	}, result.Checks)
+}
+
+func TestConfigureNetworkChecksRegressed(t *testing.T) {
+	n, committed := newTestChecksNetConfig(t, Config{Commit: true})
+
+	require.Error(t, n.ConfigureNetwork())
+
+	// Without commit confirmed, the change is reported but not rolled back.
+	require.True(t, committed())
+	require.False(t, n.Report().Hosts[0].RolledBack)
+}
+
+func TestConfigureNetworkChecksPassed(t *testing.T) {
+	n, committed := newTestChecksNetConfig(t, Config{Commit: true, CommitConfirmed: 5})
+	n.Data.Checks = n.Data.Checks[1:]
+
+	require.NoError(t, n.ConfigureNetwork())
+
+	// The commit confirmed is confirmed once the checks pass.
+	require.True(t, committed())
+	require.Equal(t, []CheckResult{
+		{Name: "uplinks", Type: CheckInterfaces, Before: 1, After: 1},
+	}, n.Report().Hosts[0].Checks)
+}
+
+func TestConfigureNetworkChecksConfirmExpired(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range testDataFiles {
+		files[name] = content
+	}
+	files["data.yaml"] = testChecksData
+
+	cases := map[string]func(running string) string{
+		// The peer recovers once the device rolls back the commit
+		// confirmed, while the checks are still verified.
+		"recovered": testBGPSummary,
+		// The peer stays down, even after the device rolled back.
+		"regressed": func() func(string) string {
+			var captured bool
+			return func(string) string {
+				if !captured {
+					captured = true
+					return testBGPSummary("")
+				}
+				return testBGPSummary("server 10.0.0.2;")
+			}
+		}(),
+	}
+
+	for name, bgpSummary := range cases {
+		t.Run(name, func(t *testing.T) {
+			device := newTestDevice(t)
+			device.ConfirmUnit = 10 * time.Millisecond
+			device.SetCommandFunc("show bgp summary", bgpSummary)
+			device.SetCommand("show interfaces terse", testInterfacesTerse)
+
+			n := newTestNetConfig(t, Config{
+				Data:            DataConfig{Directory: writeTestData(t, files)},
+				Commit:          true,
+				CommitConfirmed: 5,
+				Verify:          VerifyConfig{Timeout: 300 * time.Millisecond, Interval: 20 * time.Millisecond},
+			}, device)
+
+			require.Error(t, n.ConfigureNetwork())
+			require.Contains(t, n.Report().Hosts[0].Err.Error(), "the commit confirmed on router1.example.com is no longer active")
+
+			// The configuration the device rolled back to is neither
+			// committed again, nor is the rolled back change confirmed.
+			commits := device.Commits()
+			require.Len(t, commits, 2)
+			require.Equal(t, "root", commits[0].User)
+			require.NotContains(t, device.Running(), "server 10.0.0.2;")
+			require.False(t, n.Report().Hosts[0].RolledBack)
+		})
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	n := &NetConfig{cfg: &Config{Verify: VerifyConfig{Timeout: 10 * time.Minute}}}
+
+	require.Equal(t, 10*time.Minute, n.verifyTimeout(0))
+	require.Equal(t, 4*time.Minute, n.verifyTimeout(5))
+	require.Equal(t, time.Duration(0), n.verifyTimeout(1))
+}
+
+func TestParseCheckState(t *testing.T) {
+	cases := map[string]struct {
+		check  data.Check
+		output string
+		state  checkState
+	}{
+		"interfaces": {
+			check: data.Check{Type: CheckInterfaces},
+			output: `<interface-information>
+<physical-interface><name>
+ge-0/0/0
+</name><admin-status>up</admin-status><oper-status>up</oper-status></physical-interface>
+<physical-interface><name>ge-0/0/1</name><admin-status>up</admin-status><oper-status>down</oper-status></physical-interface>
+<physical-interface><name>ge-0/0/2</name><admin-status>down</admin-status><oper-status>down</oper-status></physical-interface>
+</interface-information>`,
+			state: checkState{items: []string{"ge-0/0/0"}, count: 1},
+		},
+		"lacp": {
+			check: data.Check{Type: CheckLACP},
+			output: `<lacp-interface-information-list>
+<lacp-interface-information>
+<lag-lacp-header><aggregate-name>ae0</aggregate-name></lag-lacp-header>
+<lag-lacp-protocol><name>xe-0/1/0</name><lacp-mux-state>Collecting distributing</lacp-mux-state></lag-lacp-protocol>
+<lag-lacp-protocol><name>xe-0/1/1</name><lacp-mux-state>Waiting</lacp-mux-state></lag-lacp-protocol>
+</lacp-interface-information>
+</lacp-interface-information-list>`,
+			state: checkState{items: []string{"ae0 xe-0/1/0"}, count: 1},
+		},
+		"routes": {
+			check: data.Check{Type: CheckRoutes, Table: "inet6.0"},
+			output: `<route-summary-information>
+<route-table><table-name>inet.0</table-name><active-route-count>12</active-route-count></route-table>
+<route-table><table-name>inet6.0</table-name><active-route-count>7</active-route-count></route-table>
+</route-summary-information>`,
+			state: checkState{count: 7},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			state, err := parseCheckState(tc.check, tc.output)
+			require.NoError(t, err)
+			require.Equal(t, tc.state, state)
+		})
+	}
+}
+
+func TestCompareCheckRoutes(t *testing.T) {
+	c := data.Check{Name: "routes", Type: CheckRoutes, Tolerance: 2}
+
+	require.Empty(t, compareCheck(c, checkState{count: 10}, checkState{count: 8}).Regressions)
+	require.Equal(t, []string{"active routes in inet.0 dropped from 10 to 7"}, compareCheck(c, checkState{count: 10}, checkState{count: 7}).Regressions)
+}
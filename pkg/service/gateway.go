@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Gateway translates JSON requests over HTTP into calls of the gRPC service.
+//
+//	GET  /v1/hosts?limit=core*       ListHosts
+//	GET  /v1/hosts/<host>/render     RenderHost
+//	GET  /v1/hosts/<host>/diff       DiffHost
+//	POST /v1/push                    PushHosts, streaming newline delimited JSON
+//	GET  /v1/runs[/<run-id>]         GetRunReport
+type Gateway struct {
+	client NetConfigClient
+}
+
+// NewGateway returns a Gateway calling the given client.
+func NewGateway(client NetConfigClient) *Gateway {
+	return &Gateway{client: client}
+}
+
+var jsonMarshaler = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The authorization header is passed on for the service to check.
+	ctx := r.Context()
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", auth)
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "v1" && parts[1] == "hosts":
+		resp, err := g.client.ListHosts(ctx, &ListHostsRequest{Limit: r.URL.Query().Get("limit")})
+		writeResponse(w, resp, err)
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "v1" && parts[1] == "hosts" && parts[3] == "render":
+		resp, err := g.client.RenderHost(ctx, &HostRequest{Host: parts[2]})
+		writeResponse(w, resp, err)
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[0] == "v1" && parts[1] == "hosts" && parts[3] == "diff":
+		resp, err := g.client.DiffHost(ctx, &HostRequest{Host: parts[2]})
+		writeResponse(w, resp, err)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[0] == "v1" && parts[1] == "push":
+		g.push(ctx, w, r)
+	case r.Method == http.MethodGet && (len(parts) == 2 || len(parts) == 3) && parts[0] == "v1" && parts[1] == "runs":
+		req := &RunReportRequest{}
+		if len(parts) == 3 {
+			req.RunId = parts[2]
+		}
+		resp, err := g.client.GetRunReport(ctx, req)
+		writeResponse(w, resp, err)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// push streams the progress of a push as newline delimited JSON.  An error
+// after the stream has started is written as a final {"error": ...} object.
+func (g *Gateway) push(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	req := &PushHostsRequest{}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body) > 0 {
+		err = protojson.Unmarshal(body, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stream, err := g.client.PushHosts(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+
+	var started bool
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			if !started {
+				writeError(w, err)
+				return
+			}
+
+			b, _ := jsonMarshaler.Marshal(status.Convert(err).Proto())
+			_, _ = w.Write([]byte(`{"error":` + string(b) + "}\n"))
+			return
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		b, err := jsonMarshaler.Marshal(msg)
+		if err != nil {
+			return
+		}
+
+		_, _ = w.Write(append(b, '\n'))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp proto.Message, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := jsonMarshaler.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	s := status.Convert(err)
+
+	b, _ := jsonMarshaler.Marshal(s.Proto())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(runtime.HTTPStatusFromCode(s.Code()))
+	_, _ = w.Write(b)
+}
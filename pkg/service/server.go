@@ -0,0 +1,368 @@
+// Package service exposes netconfig as a gRPC service, with a JSON gateway.
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// maxReports is the number of run reports retained by the server.
+const maxReports = 50
+
+// deviceMethods are the methods which open a session to a device, and which
+// require authentication even when the service is insecure.  DiffHost locks
+// the candidate of the device and loads the rendered configuration into it, as
+// PushHosts does.
+var deviceMethods = map[string]bool{
+	"/service.NetConfig/DiffHost":  true,
+	"/service.NetConfig/PushHosts": true,
+}
+
+// NetConfigFunc builds a NetConfig for a request from the given config.
+type NetConfigFunc func(cfg netconfig.Config) (*netconfig.NetConfig, error)
+
+// Server implements the NetConfig service.  A NetConfig is built for each
+// request, so that changes to the data directory and inventory are picked up
+// without a restart.
+type Server struct {
+	UnimplementedNetConfigServer
+
+	logger       log.Logger
+	cfg          netconfig.Config
+	newNetConfig NetConfigFunc
+
+	// pushing is set while a push runs.
+	pushing int32
+
+	reportMtx sync.Mutex
+	reports   []*RunReport
+}
+
+// NewServer returns a Server using the given function to build a NetConfig
+// for each request.
+func NewServer(cfg netconfig.Config, newNetConfig NetConfigFunc, logger log.Logger) *Server {
+	return &Server{
+		logger:       log.With(logger, "module", "service"),
+		cfg:          cfg,
+		newNetConfig: newNetConfig,
+	}
+}
+
+type operatorKey struct{}
+
+// authenticate returns a context carrying the name of the client when the
+// method requires authentication and the bearer token of the request matches a
+// configured token.  Every method requires authentication, since rendered
+// configurations include secrets, unless the service is insecure, when only
+// the methods which touch devices do.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if s.cfg.Serve.Insecure && !deviceMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var token string
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(v, "Bearer ") {
+			token = strings.TrimPrefix(v, "Bearer ")
+		}
+	}
+
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "a bearer token is required")
+	}
+
+	for name, t := range s.cfg.Serve.Tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return context.WithValue(ctx, operatorKey{}, name), nil
+		}
+	}
+
+	return nil, status.Error(codes.PermissionDenied, "invalid token")
+}
+
+// UnaryInterceptor authenticates the unary calls.
+func (s *Server) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamInterceptor authenticates the streaming calls.
+func (s *Server) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticatedStream) Context() context.Context {
+	return a.ctx
+}
+
+// netConfig builds a NetConfig for the hosts matching the limit.
+func (s *Server) netConfig(limit string) (*netconfig.NetConfig, error) {
+	cfg := s.cfg
+	cfg.Limit = limit
+	cfg.Commit = false
+	cfg.Interactive = false
+
+	nc, err := s.newNetConfig(cfg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return nc, nil
+}
+
+// host builds a NetConfig for a single named host.
+func (s *Server) host(name string) (*netconfig.NetConfig, netconfig.Host, error) {
+	if name == "" {
+		return nil, netconfig.Host{}, status.Error(codes.InvalidArgument, "a host is required")
+	}
+
+	nc, err := s.netConfig(name)
+	if err != nil {
+		return nil, netconfig.Host{}, err
+	}
+
+	host, err := nc.Host(name)
+	if err != nil {
+		return nil, netconfig.Host{}, status.Error(codes.NotFound, err.Error())
+	}
+
+	return nc, host, nil
+}
+
+// ListHosts returns the hosts matching the limit of the request.
+func (s *Server) ListHosts(ctx context.Context, req *ListHostsRequest) (*ListHostsResponse, error) {
+	nc, err := s.netConfig(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ListHostsResponse{}
+	for _, h := range nc.Hosts {
+		resp.Hosts = append(resp.Hosts, &Host{
+			Name:     h.NetworkHost.Name,
+			Hostname: h.HostName,
+			Role:     h.NetworkHost.Role,
+			Group:    h.NetworkHost.Group,
+			Platform: h.NetworkHost.Platform,
+		})
+	}
+
+	return resp, nil
+}
+
+// RenderHost returns the rendered configuration of a host.
+func (s *Server) RenderHost(ctx context.Context, req *HostRequest) (*RenderHostResponse, error) {
+	nc, host, err := s.host(req.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := nc.RenderHost(host)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return &RenderHostResponse{Host: host.HostName, Config: config}, nil
+}
+
+// DiffHost returns the difference between the rendered and active
+// configuration of a host.
+func (s *Server) DiffHost(ctx context.Context, req *HostRequest) (*DiffHostResponse, error) {
+	nc, host, err := s.host(req.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := nc.DiffHost(host)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	added, removed := diffLines(diff)
+
+	return &DiffHostResponse{Host: host.HostName, Diff: diff, Added: added, Removed: removed}, nil
+}
+
+// PushHosts configures the hosts of the request, streaming the progress of
+// each host.  Only one push runs at a time, and the hosts must be given, so
+// that an empty request does not push to every host.
+func (s *Server) PushHosts(req *PushHostsRequest, stream NetConfig_PushHostsServer) error {
+	if len(req.Hosts) == 0 {
+		return status.Error(codes.InvalidArgument, "at least one host is required, eg: \"*\" to push every host")
+	}
+
+	if !atomic.CompareAndSwapInt32(&s.pushing, 0, 1) {
+		return status.Error(codes.Unavailable, "a push is already running")
+	}
+	defer atomic.StoreInt32(&s.pushing, 0)
+
+	cfg := s.cfg
+	cfg.Limit = strings.Join(req.Hosts, ",")
+	cfg.Commit = req.Commit
+	cfg.CommitConfirmed = int(req.CommitConfirmed)
+	cfg.Interactive = false
+
+	if operator, ok := stream.Context().Value(operatorKey{}).(string); ok {
+		cfg.Operator = operator
+	}
+
+	nc, err := s.newNetConfig(cfg)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	runID := nc.Provenance().RunID
+
+	_ = level.Info(s.logger).Log("msg", "push requested", "run_id", runID, "operator", cfg.Operator, "hosts", cfg.Limit, "commit", cfg.Commit)
+
+	progress := make(chan netconfig.Progress)
+	nc.SetProgressFunc(func(p netconfig.Progress) {
+		progress <- p
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- nc.ConfigureNetwork()
+		close(progress)
+	}()
+
+	// The push continues when the client goes away, so that no host is left
+	// part way through a change.
+	var sendErr error
+	for p := range progress {
+		if sendErr != nil {
+			continue
+		}
+
+		msg := &PushProgress{
+			RunId: runID,
+			Host:  p.Host,
+			Phase: p.Phase,
+			Time:  timestamppb.Now(),
+		}
+		if p.Result != nil {
+			msg.Result = hostResult(*p.Result)
+		}
+
+		sendErr = stream.Send(msg)
+	}
+
+	runErr := <-done
+
+	s.addReport(runReport(nc.Report()))
+
+	if runErr != nil {
+		return status.Error(codes.Aborted, runErr.Error())
+	}
+
+	return sendErr
+}
+
+// GetRunReport returns the report of a push made by the server.
+func (s *Server) GetRunReport(ctx context.Context, req *RunReportRequest) (*RunReport, error) {
+	s.reportMtx.Lock()
+	defer s.reportMtx.Unlock()
+
+	for i := len(s.reports) - 1; i >= 0; i-- {
+		if req.RunId == "" || s.reports[i].RunId == req.RunId {
+			return s.reports[i], nil
+		}
+	}
+
+	return nil, status.Error(codes.NotFound, "run not found")
+}
+
+func (s *Server) addReport(r *RunReport) {
+	s.reportMtx.Lock()
+	defer s.reportMtx.Unlock()
+
+	s.reports = append(s.reports, r)
+	if len(s.reports) > maxReports {
+		s.reports = s.reports[len(s.reports)-maxReports:]
+	}
+}
+
+func runReport(r netconfig.RunReport) *RunReport {
+	report := &RunReport{
+		RunId:    r.RunID,
+		Operator: r.Operator,
+		Revision: r.Revision,
+		Started:  timestamppb.New(r.Started),
+		Finished: timestamppb.New(r.Finished),
+	}
+
+	for _, h := range r.Hosts {
+		report.Hosts = append(report.Hosts, hostResult(h))
+	}
+
+	return report
+}
+
+func hostResult(r netconfig.HostResult) *HostResult {
+	result := &HostResult{
+		Host:      r.Host,
+		Changed:   r.Changed,
+		Committed: r.Committed,
+		Diff:      r.Diff,
+		Skipped:   r.Skipped,
+		Preflight: r.Preflight,
+	}
+
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	}
+
+	for _, re := range r.RoutingEngines {
+		result.RoutingEngines = append(result.RoutingEngines, &RoutingEngineResult{
+			Name:       re.Name,
+			Mastership: re.Mastership,
+			Committed:  re.Committed,
+			Error:      re.Err,
+		})
+	}
+
+	return result
+}
+
+// diffLines counts the lines added and removed by a diff.
+func diffLines(diff string) (added, removed int32) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+
+	return added, removed
+}
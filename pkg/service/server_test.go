@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/scottdware/go-junos"
+	"github.com/stretchr/testify/require"
+	"github.com/xaque208/znet/modules/inventory"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/xaque208/netconfig/pkg/junostest"
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+var testDataFiles = map[string]string{
+	"data.yaml": `
+template_dir: templates
+template_paths:
+  - "platform/{{ .NetworkHost.Platform }}"
+hierarchy:
+  - "global.yaml"
+`,
+	"data/global.yaml": `
+ntp_servers:
+  - 10.0.0.1
+`,
+	"templates/platform/junos/system.tmpl": `
+system {
+    host-name {{ .NetworkHost.Name }};
+    ntp {
+{{- range .Data.NTPServers }}
+        server {{ . }};
+{{- end }}
+    }
+}
+`,
+}
+
+const testToken = "s3cret"
+
+// newTestServer returns a server for a single host, router1, whose sessions
+// are opened to the simulated device.
+func newTestServer(t *testing.T, device *junostest.Server, insecure bool) *Server {
+	dir := t.TempDir()
+	for name, content := range testDataFiles {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	}
+
+	inv := &inventory.MockInventory{
+		ListNetworkHostResponse: []inventory.NetworkHost{
+			{Name: "router1", Domain: "example.com", Platform: "junos", Role: "core"},
+		},
+	}
+
+	cfg := netconfig.Config{
+		Data:  netconfig.DataConfig{Directory: dir},
+		Serve: netconfig.ServeConfig{Tokens: map[string]string{"chatops": testToken}, Insecure: insecure},
+	}
+
+	return NewServer(cfg, func(c netconfig.Config) (*netconfig.NetConfig, error) {
+		nc, err := netconfig.NewWithInventory(c, inv, log.NewNopLogger())
+		if err != nil {
+			return nil, err
+		}
+
+		nc.SetSessionDialer(func(netconfig.Host) (*junos.Junos, error) {
+			return device.Dial()
+		})
+
+		return nc, nil
+	}, log.NewNopLogger())
+}
+
+// newTestClient returns a client of a server for router1, which requires a
+// token for every call unless insecure.
+func newTestClient(t *testing.T, device *junostest.Server, insecure bool) NetConfigClient {
+	srv := newTestServer(t, device, insecure)
+
+	lis := bufconn.Listen(1024 * 1024)
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(srv.UnaryInterceptor),
+		grpc.StreamInterceptor(srv.StreamInterceptor),
+	)
+	RegisterNetConfigServer(grpcServer, srv)
+
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	// nolint: staticcheck
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return NewNetConfigClient(conn)
+}
+
+func newTestDevice(t *testing.T) *junostest.Server {
+	device, err := junostest.NewServer("router1", "system { host-name router1; }")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = device.Close() })
+
+	return device
+}
+
+func TestServer(t *testing.T) {
+	device := newTestDevice(t)
+	client := newTestClient(t, device, false)
+	ctx := context.Background()
+
+	// Every call requires a token, as rendered configurations include
+	// secrets.
+	_, err := client.ListHosts(ctx, &ListHostsRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.RenderHost(ctx, &HostRequest{Host: "router1"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testToken)
+
+	hosts, err := client.ListHosts(authCtx, &ListHostsRequest{})
+	require.NoError(t, err)
+	require.Len(t, hosts.Hosts, 1)
+	require.Equal(t, "router1.example.com", hosts.Hosts[0].Hostname)
+	require.Equal(t, "core", hosts.Hosts[0].Role)
+
+	hosts, err = client.ListHosts(authCtx, &ListHostsRequest{Limit: "edge*"})
+	require.NoError(t, err)
+	require.Empty(t, hosts.Hosts)
+
+	rendered, err := client.RenderHost(authCtx, &HostRequest{Host: "router1"})
+	require.NoError(t, err)
+	require.Contains(t, rendered.Config, "server 10.0.0.1;")
+
+	_, err = client.RenderHost(authCtx, &HostRequest{Host: "router2"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DiffHost(ctx, &HostRequest{Host: "router1"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.NotContains(t, device.RPCs(), "lock-configuration")
+
+	diff, err := client.DiffHost(authCtx, &HostRequest{Host: "router1"})
+	require.NoError(t, err)
+	require.Contains(t, diff.Diff, "+  server 10.0.0.1;")
+	require.Equal(t, int32(1), diff.Added)
+	require.Empty(t, device.Commits())
+
+	stream, err := client.PushHosts(ctx, &PushHostsRequest{Commit: true})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	wrongCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	stream, err = client.PushHosts(wrongCtx, &PushHostsRequest{Commit: true})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// A push without hosts is refused rather than pushing every host.
+	stream, err = client.PushHosts(authCtx, &PushHostsRequest{Commit: true})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Empty(t, device.Commits())
+
+	stream, err = client.PushHosts(authCtx, &PushHostsRequest{Hosts: []string{"router1"}, Commit: true})
+	require.NoError(t, err)
+
+	var progress []*PushProgress
+	for {
+		p, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		progress = append(progress, p)
+	}
+
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	require.Equal(t, "done", last.Phase)
+	require.True(t, last.Result.Committed)
+	require.Empty(t, last.Result.Error)
+	require.Len(t, device.Commits(), 1)
+	require.Contains(t, device.Commits()[0].Log, "operator=chatops")
+
+	report, err := client.GetRunReport(authCtx, &RunReportRequest{})
+	require.NoError(t, err)
+	require.Equal(t, last.RunId, report.RunId)
+	require.Equal(t, "chatops", report.Operator)
+	require.Len(t, report.Hosts, 1)
+
+	_, err = client.GetRunReport(authCtx, &RunReportRequest{RunId: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerInsecure(t *testing.T) {
+	device := newTestDevice(t)
+	client := newTestClient(t, device, true)
+	ctx := context.Background()
+
+	// Only the calls which touch devices require a token.
+	hosts, err := client.ListHosts(ctx, &ListHostsRequest{})
+	require.NoError(t, err)
+	require.Len(t, hosts.Hosts, 1)
+
+	_, err = client.RenderHost(ctx, &HostRequest{Host: "router1"})
+	require.NoError(t, err)
+
+	_, err = client.DiffHost(ctx, &HostRequest{Host: "router1"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestHostResult(t *testing.T) {
+	result := hostResult(netconfig.HostResult{
+		Host:      "router1.example.com",
+		Skipped:   true,
+		Preflight: []string{"alarms: 1 major alarm"},
+		RoutingEngines: []netconfig.RoutingEngineResult{
+			{Name: "re0", Mastership: "master", Committed: true},
+			{Name: "re1", Mastership: "backup", Err: "commit failed on re1"},
+		},
+	})
+
+	require.True(t, result.Skipped)
+	require.Equal(t, []string{"alarms: 1 major alarm"}, result.Preflight)
+	require.Len(t, result.RoutingEngines, 2)
+	require.True(t, result.RoutingEngines[0].Committed)
+	require.Equal(t, "backup", result.RoutingEngines[1].Mastership)
+	require.Equal(t, "commit failed on re1", result.RoutingEngines[1].Error)
+}
+
+func TestGateway(t *testing.T) {
+	device := newTestDevice(t)
+	gw := httptest.NewServer(NewGateway(newTestClient(t, device, true)))
+	t.Cleanup(gw.Close)
+
+	resp, err := http.Get(gw.URL + "/v1/hosts")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var hosts struct {
+		Hosts []struct {
+			Hostname string `json:"hostname"`
+		} `json:"hosts"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&hosts))
+	require.Len(t, hosts.Hosts, 1)
+	require.Equal(t, "router1.example.com", hosts.Hosts[0].Hostname)
+
+	resp, err = http.Get(gw.URL + "/v1/hosts/router2/render")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(gw.URL+"/v1/push", "application/json", strings.NewReader(`{"commit": true}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodPost, gw.URL+"/v1/push", strings.NewReader(`{"hosts": ["router1"], "commit": true}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	require.Contains(t, lines[len(lines)-1], `"phase":"done"`)
+	require.Len(t, device.Commits(), 1)
+
+	resp, err = http.Get(gw.URL + "/v1/runs")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
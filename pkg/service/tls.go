@@ -0,0 +1,65 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// TLSConfig returns the TLS configuration of the listeners of the service, and
+// that of a client of the service, ie the JSON gateway, which trusts the
+// certificate of the service.  Both are nil when the service is insecure.
+// Otherwise the certificate, key and at least one token are required.
+func TLSConfig(cfg netconfig.ServeConfig) (*tls.Config, *tls.Config, error) {
+	if cfg.Insecure {
+		return nil, nil, nil
+	}
+
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, nil, fmt.Errorf("a TLS certificate and key are required, unless the service is insecure")
+	}
+
+	if len(cfg.Tokens) == 0 {
+		return nil, nil, fmt.Errorf("at least one token is required, unless the service is insecure")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load TLS certificate")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse TLS certificate")
+	}
+
+	var serverName string
+	switch {
+	case len(leaf.DNSNames) > 0:
+		serverName = leaf.DNSNames[0]
+	case len(leaf.IPAddresses) > 0:
+		serverName = leaf.IPAddresses[0].String()
+	default:
+		return nil, nil, fmt.Errorf("TLS certificate %s has no DNS or IP subject alternative name", cfg.TLSCertFile)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	server := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	client := &tls.Config{
+		RootCAs:    roots,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return server, client, nil
+}
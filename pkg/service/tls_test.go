@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/xaque208/netconfig/pkg/netconfig"
+)
+
+// writeTestCertificate writes a self-signed certificate for
+// netconfig.example.com and its key, returning their paths.
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "netconfig"},
+		DNSNames:     []string{"netconfig.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	tokens := map[string]string{"chatops": testToken}
+
+	server, client, err := TLSConfig(netconfig.ServeConfig{Insecure: true})
+	require.NoError(t, err)
+	require.Nil(t, server)
+	require.Nil(t, client)
+
+	_, _, err = TLSConfig(netconfig.ServeConfig{Tokens: tokens})
+	require.EqualError(t, err, "a TLS certificate and key are required, unless the service is insecure")
+
+	_, _, err = TLSConfig(netconfig.ServeConfig{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	require.EqualError(t, err, "at least one token is required, unless the service is insecure")
+
+	server, client, err = TLSConfig(netconfig.ServeConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, Tokens: tokens})
+	require.NoError(t, err)
+	require.Equal(t, "netconfig.example.com", client.ServerName)
+
+	// The gateway, as a client trusting the certificate of the service, calls
+	// it over TLS.
+	device := newTestDevice(t)
+	srv := newTestServer(t, device, false)
+
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(server)),
+		grpc.UnaryInterceptor(srv.UnaryInterceptor),
+		grpc.StreamInterceptor(srv.StreamInterceptor),
+	)
+	RegisterNetConfigServer(grpcServer, srv)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(client)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testToken)
+	hosts, err := NewNetConfigClient(conn).ListHosts(ctx, &ListHostsRequest{})
+	require.NoError(t, err)
+	require.Len(t, hosts.Hosts, 1)
+
+	// A client which does not use TLS is refused.
+	// nolint: staticcheck
+	plain, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = plain.Close() })
+
+	_, err = NewNetConfigClient(plain).ListHosts(ctx, &ListHostsRequest{})
+	require.Error(t, err)
+}